@@ -0,0 +1,310 @@
+package pala
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// IndexError is returned when a program indexes a list out of range or looks up a key that is not in a map.
+type IndexError struct {
+	Pos    Position
+	Index  interface{} // The index, slice bounds such as "1:3", or map key.
+	Length int         // The length of the list or map.
+	Map    bool        // Whether a map was indexed.
+}
+
+func (e *IndexError) Error() string {
+	if e.Map {
+		return fmt.Sprintf("%s key %v not found in map", e.Pos.prefix(), e.Index)
+	}
+	return fmt.Sprintf("%s index %v out of range for list of length %d", e.Pos.prefix(), e.Index, e.Length)
+}
+
+// accessStep reads a field, an element or a part of a value.
+type accessStep[C any] func(rt *runtime[C], value reflect.Value) reflect.Value
+
+// readVariable reads a variable from the program variables. The name of the variable may be followed by accessors that
+// are resolved against the type of the variable: fields such as `$user.name`, indices of lists and keys of maps such as
+// `$xs[0]` or `$ages[$name]`, and slices of lists such as `$xs[1:3]`.
+func (p *Parser[C]) readVariable(variableName token) (astNode[C], error) {
+	end := strings.IndexAny(variableName.value, ".[")
+	if end < 0 {
+		end = len(variableName.value)
+	}
+	name, path := variableName.value[:end], variableName.value[end:]
+
+	variable, isDefined := p.definedVariables[name]
+	if !isDefined {
+		return astNode[C]{}, fmtTokenErr(variableName, fmt.Sprintf("encountered undeclared variable %s", name))
+	}
+	if path == "" {
+		return astNode[C]{
+			returnType: variable.Type,
+			evaluate: func(rt *runtime[C]) interface{} {
+				return rt.variables[name]
+			},
+			start: variableName.pos,
+			end:   variableName.end(),
+			kind:  variable.kind,
+		}, nil
+	}
+
+	steps, returnType, err := p.resolveAccess(variable.Type, path, variableName, end)
+	if err != nil {
+		return astNode[C]{}, err
+	}
+	return astNode[C]{
+		returnType: returnType,
+		evaluate: func(rt *runtime[C]) interface{} {
+			value := reflect.ValueOf(rt.variables[name])
+			for _, step := range steps {
+				value = step(rt, value)
+			}
+			if !value.IsValid() {
+				return nil
+			}
+			return value.Interface()
+		},
+		start: variableName.pos,
+		end:   variableName.end(),
+	}, nil
+}
+
+// resolveAccess resolves the accessors in path, which starts at the given offset in the variable token.
+func (p *Parser[C]) resolveAccess(valueType reflect.Type, path string, variableName token, offset int) ([]accessStep[C], reflect.Type, error) {
+	var steps []accessStep[C]
+	for path != "" {
+		if valueType == nil {
+			return nil, nil, fmtTokenErr(variableName, fmt.Sprintf("cannot access %s of a value without type", path))
+		}
+
+		var step accessStep[C]
+		var consumed int
+		var err error
+		if path[0] == '.' {
+			consumed = strings.IndexAny(path[1:], ".[") + 1
+			if consumed == 0 {
+				consumed = len(path)
+			}
+			step, valueType, err = resolveField[C](valueType, path[1:consumed], variableName)
+		} else {
+			consumed = strings.IndexByte(path, ']') + 1
+			for depth := strings.Count(path[:consumed], "[") - 1; depth > 0 && consumed > 0; depth-- {
+				next := strings.IndexByte(path[consumed:], ']')
+				if next < 0 {
+					consumed = 0
+					break
+				}
+				consumed += next + 1
+			}
+			if consumed == 0 {
+				return nil, nil, fmtTokenErr(variableName, fmt.Sprintf("missing ] in %s", variableName.value))
+			}
+			pos := variableName.pos
+			pos.Col += utf8.RuneCountInString(variableName.value[:offset]) + 1
+			step, valueType, err = p.resolveIndex(valueType, path[1:consumed-1], pos, variableName)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		steps = append(steps, step)
+		offset += consumed
+		path = path[consumed:]
+	}
+	return steps, valueType, nil
+}
+
+// resolveField resolves a field of a struct, or of a pointer to a struct. Fields are looked up by their exact name and
+// then by their name with the first letter in upper case.
+func resolveField[C any](valueType reflect.Type, name string, variableName token) (accessStep[C], reflect.Type, error) {
+	if name == "" {
+		return nil, nil, fmtTokenErr(variableName, "missing field name")
+	}
+
+	structType := derefType(valueType)
+	if structType.Kind() != reflect.Struct {
+		return nil, nil, fmtTokenErr(variableName, fmt.Sprintf("cannot access field %s of type %s", name, valueType))
+	}
+
+	field, found := lookupField(structType, name)
+	if !found {
+		return nil, nil, fmtTokenErr(variableName, fmt.Sprintf("type %s has no field %s", structType, name))
+	}
+	if !field.IsExported() {
+		return nil, nil, fmtTokenErr(variableName, fmt.Sprintf("field %s of type %s is unexported", field.Name, structType))
+	}
+
+	step := func(rt *runtime[C], value reflect.Value) reflect.Value {
+		value = deref(value, variableName, "field "+name)
+		next, err := value.FieldByIndexErr(field.Index)
+		if err != nil {
+			panic(abort{err: &RuntimeError{Pos: variableName.pos, Msg: fmt.Sprintf("cannot access field %s in %s: %s", name, variableName.value, err)}})
+		}
+		return next
+	}
+	return step, field.Type, nil
+}
+
+// lookupField finds a field by its exact name or its capitalized name, preferring exported fields.
+func lookupField(structType reflect.Type, name string) (reflect.StructField, bool) {
+	exact, hasExact := structType.FieldByName(name)
+	if hasExact && exact.IsExported() {
+		return exact, true
+	}
+
+	r, size := utf8.DecodeRuneInString(name)
+	capitalized, hasCapitalized := structType.FieldByName(string(unicode.ToUpper(r)) + name[size:])
+	if hasCapitalized && capitalized.IsExported() {
+		return capitalized, true
+	}
+
+	if hasExact {
+		return exact, true
+	}
+	return capitalized, hasCapitalized
+}
+
+// resolveIndex resolves the index, slice bounds or key between brackets, which starts at the given position.
+func (p *Parser[C]) resolveIndex(valueType reflect.Type, index string, pos Position, variableName token) (accessStep[C], reflect.Type, error) {
+	indexedType := derefType(valueType)
+
+	switch indexedType.Kind() {
+	case reflect.Slice, reflect.Array:
+		if low, high, isSlice := strings.Cut(index, ":"); isSlice && !strings.Contains(index, "[") {
+			if indexedType.Kind() != reflect.Slice {
+				return nil, nil, &ParseError{Pos: pos, Msg: fmt.Sprintf("cannot slice %s", valueType)}
+			}
+			return p.resolveSlice(indexedType, low, high, pos, variableName)
+		}
+
+		operand, err := p.indexOperand(index, pos, reflect.TypeOf(0))
+		if err != nil {
+			return nil, nil, err
+		}
+		step := func(rt *runtime[C], value reflect.Value) reflect.Value {
+			value = deref(value, variableName, "index "+index)
+			i := operand.evaluate(rt).(int)
+			if i < 0 || i >= value.Len() {
+				panic(abort{err: &IndexError{Pos: pos, Index: i, Length: value.Len()}})
+			}
+			return value.Index(i)
+		}
+		return step, indexedType.Elem(), nil
+
+	case reflect.Map:
+		if strings.Contains(index, ":") && !strings.Contains(index, "[") {
+			return nil, nil, &ParseError{Pos: pos, Msg: fmt.Sprintf("cannot slice %s", valueType)}
+		}
+		operand, err := p.indexOperand(index, pos, indexedType.Key())
+		if err != nil {
+			return nil, nil, err
+		}
+		step := func(rt *runtime[C], value reflect.Value) reflect.Value {
+			value = deref(value, variableName, "key "+index)
+			key := operand.evaluate(rt)
+			element := value.MapIndex(reflect.ValueOf(key))
+			if !element.IsValid() {
+				panic(abort{err: &IndexError{Pos: pos, Index: key, Length: value.Len(), Map: true}})
+			}
+			return element
+		}
+		return step, indexedType.Elem(), nil
+
+	default:
+		return nil, nil, &ParseError{Pos: pos, Msg: fmt.Sprintf("cannot index %s", valueType)}
+	}
+}
+
+// resolveSlice resolves slice bounds, either of which may be omitted.
+func (p *Parser[C]) resolveSlice(sliceType reflect.Type, low, high string, pos Position, variableName token) (accessStep[C], reflect.Type, error) {
+	var bounds [2]*astNode[C]
+	for i, bound := range []string{low, high} {
+		if bound == "" {
+			continue
+		}
+		boundPos := pos
+		if i == 1 {
+			boundPos.Col += utf8.RuneCountInString(low) + 1
+		}
+		operand, err := p.indexOperand(bound, boundPos, reflect.TypeOf(0))
+		if err != nil {
+			return nil, nil, err
+		}
+		bounds[i] = &operand
+	}
+
+	step := func(rt *runtime[C], value reflect.Value) reflect.Value {
+		value = deref(value, variableName, "slice "+low+":"+high)
+		from, to := 0, value.Len()
+		if bounds[0] != nil {
+			from = bounds[0].evaluate(rt).(int)
+		}
+		if bounds[1] != nil {
+			to = bounds[1].evaluate(rt).(int)
+		}
+		if from < 0 || from > to || to > value.Len() {
+			panic(abort{err: &IndexError{Pos: pos, Index: fmt.Sprintf("%d:%d", from, to), Length: value.Len()}})
+		}
+		return value.Slice(from, to)
+	}
+	return step, sliceType, nil
+}
+
+// indexOperand parses an index or key, which is either a variable or a literal. Integer indices of lists are parsed
+// directly, keys of maps are evaluated by the literal evaluators of the language for the key type.
+func (p *Parser[C]) indexOperand(text string, pos Position, expectedType reflect.Type) (astNode[C], error) {
+	if text == "" {
+		return astNode[C]{}, &ParseError{Pos: pos, Msg: "missing index"}
+	}
+
+	var operand astNode[C]
+	var err error
+	switch {
+	case strings.HasPrefix(text, "$"):
+		operand, err = p.readVariable(token{tpe: tokenVariable, pos: pos, value: text})
+	case expectedType == reflect.TypeOf(0):
+		n, convErr := strconv.Atoi(text)
+		if convErr != nil {
+			return astNode[C]{}, &ParseError{Pos: pos, Msg: fmt.Sprintf("invalid index %s", text)}
+		}
+		operand = valueNode[C](expectedType, n)
+	default:
+		operand, err = p.parseLiteralAs(token{tpe: tokenLiteral, pos: pos, value: text}, expectedType)
+	}
+	if err != nil {
+		return astNode[C]{}, err
+	}
+
+	if operand.returnType != expectedType &&
+		!(expectedType.Kind() == reflect.Interface && operand.returnType != nil && operand.returnType.Implements(expectedType)) {
+		return astNode[C]{}, &ParseError{Pos: pos, Msg: fmt.Sprintf("index %s must be of type %s but got %s", text, expectedType, describeType(operand))}
+	}
+	return operand, nil
+}
+
+// derefType returns the type that pointers of the given type point to, or the type itself if it is not a pointer.
+func derefType(valueType reflect.Type) reflect.Type {
+	for valueType.Kind() == reflect.Pointer {
+		valueType = valueType.Elem()
+	}
+	return valueType
+}
+
+// deref follows pointers. It aborts the run if a pointer is nil.
+func deref(value reflect.Value, variableName token, access string) reflect.Value {
+	for value.Kind() == reflect.Pointer || (value.Kind() == reflect.Interface && !value.IsNil()) {
+		if value.IsNil() {
+			panic(abort{err: &RuntimeError{Pos: variableName.pos, Msg: fmt.Sprintf("cannot access %s of nil pointer in %s", access, variableName.value)}})
+		}
+		value = value.Elem()
+	}
+	if !value.IsValid() {
+		panic(abort{err: &RuntimeError{Pos: variableName.pos, Msg: fmt.Sprintf("cannot access %s of nil in %s", access, variableName.value)}})
+	}
+	return value
+}
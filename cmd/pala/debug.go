@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/RoelofRuis/pala"
+)
+
+const debugHelp = `Commands:
+  break [file:]<line> [if <$variable> == <value>]   pause before the statement on the line, counting from 1
+  clear [file:]<line>                               remove the breakpoints on the line
+                                                    files are relative to the script, which is the default
+  continue, c                                       resume until the next breakpoint
+  step, s                                           step into the next statement or operator call
+  next, n                                           step over to the next statement or operator call
+  out, o                                            step out of the current statement
+  vars, v                                           list the variables
+  quit, q                                           abort the program
+`
+
+// debugFile runs a script file in the debugger, reading commands from in and writing to out.
+// The program is paused before its first statement to allow breakpoints to be set.
+func debugFile(path string, in io.Reader, out io.Writer) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	lexer := pala.NewLexer(bufio.NewReader(file), pala.WithSource(path))
+	prog, err := pala.NewParser(lexer, newDemoLanguage(), pala.WithImportFS(os.DirFS(filepath.Dir(path)))).Parse()
+	if err != nil {
+		return err
+	}
+
+	debugger := pala.NewDebugger(prog)
+	debugger.SetStopOnEntry(true)
+	debugger.Start(&demoContext{out: out})
+
+	scanner := bufio.NewScanner(in)
+	for {
+		stop, paused := debugger.Wait()
+		if !paused {
+			break
+		}
+		printStop(out, path, stop)
+
+		if !debugCommands(scanner, out, path, debugger, stop) {
+			debugger.Quit()
+		}
+	}
+
+	if err := debugger.Err(); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// debugCommands reads commands until one of them resumes the program. It returns false if the program should be quit.
+func debugCommands(scanner *bufio.Scanner, out io.Writer, script string, debugger *pala.Debugger[*demoContext], stop pala.Stop) bool {
+	for {
+		_, _ = fmt.Fprint(out, "(debug) ")
+		if !scanner.Scan() {
+			return false
+		}
+
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch fields[0] {
+		case "break", "b":
+			breakpoint, err := parseBreakpoint(fields[1:], script)
+			if err != nil {
+				_, _ = fmt.Fprintf(out, "error: %s\n", err)
+				continue
+			}
+			debugger.SetBreakpoint(breakpoint)
+		case "clear":
+			if len(fields) != 2 {
+				_, _ = fmt.Fprintln(out, "error: expected a line")
+				continue
+			}
+			line, err := parseLine(fields[1], script)
+			if err != nil {
+				_, _ = fmt.Fprintf(out, "error: %s\n", err)
+				continue
+			}
+			debugger.ClearBreakpoints(line)
+		case "continue", "c":
+			debugger.Continue()
+			return true
+		case "step", "s":
+			debugger.StepInto()
+			return true
+		case "next", "n":
+			debugger.StepOver()
+			return true
+		case "out", "o":
+			debugger.StepOut()
+			return true
+		case "vars", "v":
+			printVariables(out, stop.Variables)
+		case "quit", "q":
+			return false
+		default:
+			_, _ = fmt.Fprint(out, debugHelp)
+		}
+	}
+}
+
+// parseBreakpoint parses the arguments of the break command.
+func parseBreakpoint(args []string, script string) (pala.Breakpoint, error) {
+	if len(args) != 1 && !(len(args) == 5 && args[1] == "if" && args[3] == "==") {
+		return pala.Breakpoint{}, fmt.Errorf("expected break [file:]<line> [if <$variable> == <value>]")
+	}
+
+	line, err := parseLine(args[0], script)
+	if err != nil {
+		return pala.Breakpoint{}, err
+	}
+
+	breakpoint := pala.Breakpoint{Source: line.Source, Line: line.Line}
+	if len(args) == 5 {
+		name, value := args[2], args[4]
+		breakpoint.Condition = func(variables map[string]interface{}) bool {
+			actual, has := variables[name]
+			return has && fmt.Sprint(actual) == value
+		}
+	}
+	return breakpoint, nil
+}
+
+// parseLine parses a one based line number, optionally preceded by a file relative to the script such as `lib.pala:3`.
+// Imported files have their path joined to the directory of the script as source.
+func parseLine(arg string, script string) (pala.SourceLine, error) {
+	source := script
+	if i := strings.LastIndex(arg, ":"); i >= 0 {
+		source = path.Join(path.Dir(script), arg[:i])
+		arg = arg[i+1:]
+	}
+	line, err := strconv.Atoi(arg)
+	if err != nil || line < 1 {
+		return pala.SourceLine{}, fmt.Errorf("invalid line %s", arg)
+	}
+	return pala.SourceLine{Source: source, Line: line - 1}, nil
+}
+
+func printStop(out io.Writer, script string, stop pala.Stop) {
+	location := fmt.Sprintf("line %d", stop.Event.Pos.Line+1)
+	if stop.Event.Pos.Source != script {
+		location = stop.Event.Pos.SourceLine().String()
+	}
+
+	switch stop.Event.Kind {
+	case pala.TraceStatement:
+		_, _ = fmt.Fprintf(out, "[%s] before statement\n", location)
+	case pala.TraceOperator:
+		call := []string{stop.Event.Symbol}
+		for _, operand := range stop.Event.Operands {
+			call = append(call, fmt.Sprint(operand))
+		}
+		_, _ = fmt.Fprintf(out, "[%s] before %s\n", location, strings.Join(call, " "))
+	}
+}
+
+func printVariables(out io.Writer, variables map[string]interface{}) {
+	var names []string
+	for name := range variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		_, _ = fmt.Fprintf(out, "%s = %v\n", name, variables[name])
+	}
+}
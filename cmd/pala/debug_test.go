@@ -0,0 +1,31 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDebugFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "script.pala")
+	script := "$a + 1 2\n$a * $a 3\necho $a\n"
+	if err := os.WriteFile(path, []byte(script), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	in := strings.NewReader("break 3 if $a == 9\nc\nvars\ns\nc\n")
+	out := &strings.Builder{}
+	if err := debugFile(path, in, out); err != nil {
+		t.Fatalf("expected script to be debugged: %s", err)
+	}
+
+	expected := "[line 1] before statement\n" +
+		"(debug) (debug) [line 3] before statement\n" +
+		"(debug) $a = 9\n" +
+		"(debug) [line 3] before echo 9\n" +
+		"(debug) 9\n"
+	if out.String() != expected {
+		t.Errorf("expected output\n%s\nbut got\n%s", expected, out.String())
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/RoelofRuis/pala"
+)
+
+// demoContext is the context of the demo language, it determines where output is written.
+type demoContext struct {
+	out io.Writer
+}
+
+// newDemoLanguage constructs a small language with integers, strings and rationals and some basic operators.
+func newDemoLanguage() *pala.Language[*demoContext] {
+	lang := pala.NewLanguage[*demoContext]()
+
+	lang.BindOperator("+", add, pala.WithDoc("Adds two integers."))
+	lang.BindOperator("-", sub, pala.WithDoc("Subtracts the second integer from the first."))
+	lang.BindOperator("*", mul, pala.WithDoc("Multiplies two integers."))
+	lang.BindOperator("/", div, pala.WithDoc("Divides the first integer by the second, rounding towards zero."))
+	lang.BindOperator("rat+", addRat, pala.WithDoc("Adds two rationals."))
+	lang.BindOperator("rat*", mulRat, pala.WithDoc("Multiplies two rationals."))
+	lang.BindOperator("concat", concat, pala.WithDoc("Concatenates two strings."))
+	lang.BindOperator("echo", echo, pala.WithDoc("Writes a value to the output."))
+
+	lang.BindLiteralEvaluator(pala.ParseInt)
+	lang.BindLiteralEvaluator(pala.ParseRational)
+	lang.BindLiteralEvaluator(pala.ParseQuotedString)
+
+	return lang
+}
+
+func add(a, b int) int {
+	return a + b
+}
+
+func sub(a, b int) int {
+	return a - b
+}
+
+func mul(a, b int) int {
+	return a * b
+}
+
+func div(a, b int) int {
+	return a / b
+}
+
+func addRat(a, b *big.Rat) *big.Rat {
+	return new(big.Rat).Add(a, b)
+}
+
+func mulRat(a, b *big.Rat) *big.Rat {
+	return new(big.Rat).Mul(a, b)
+}
+
+func concat(a, b string) string {
+	return a + b
+}
+
+func echo(c *demoContext, v any) {
+	_, _ = fmt.Fprintln(c.out, v)
+}
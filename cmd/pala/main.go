@@ -0,0 +1,123 @@
+// Command pala runs scripts written in a small demo language built with pala.
+//
+// Usage:
+//
+//	pala run [-trace] [-profile <out>] <file>...   run one or more script files
+//	pala debug <file>                             run a script file in the debugger
+//	pala repl                                     start an interactive session
+//	pala lsp                                      start a language server on the standard input and output
+//	pala dap                                      start a debug adapter on the standard input and output
+//
+// Running pala without arguments starts an interactive session.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/RoelofRuis/pala"
+	"github.com/RoelofRuis/pala/dap"
+	"github.com/RoelofRuis/pala/lsp"
+	"github.com/RoelofRuis/pala/repl"
+)
+
+const usage = `usage:
+  pala run [-trace] [-profile <out>] <file>...   run one or more script files, optionally tracing or profiling them
+  pala debug <file>                             run a script file in the debugger
+  pala repl                                     start an interactive session
+  pala lsp                                      start a language server on the standard input and output
+  pala dap                                      start a debug adapter on the standard input and output
+`
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	if len(args) == 0 {
+		return runRepl()
+	}
+
+	switch args[0] {
+	case "run":
+		flags := flag.NewFlagSet("run", flag.ContinueOnError)
+		trace := flags.Bool("trace", false, "write a trace of every operator call to the standard error")
+		profile := flags.String("profile", "", "write a pprof profile to the given file and a profile report to the standard error")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		if flags.NArg() == 0 {
+			return fmt.Errorf("no script files given\n%s", usage)
+		}
+
+		var options []pala.RunOption
+		if *trace {
+			options = append(options, pala.WithTracer(pala.NewTextTracer(os.Stderr)))
+		}
+		var profiler *pala.Profiler
+		if *profile != "" {
+			profiler = pala.NewProfiler()
+			options = append(options, pala.WithTracer(profiler))
+		}
+		programs, err := pala.ParseFiles(newDemoLanguage(), flags.Args())
+		if err != nil {
+			return err
+		}
+		for _, path := range flags.Args() {
+			if err := programs[path].Run(&demoContext{out: os.Stdout}, options...); err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+		}
+		if profiler != nil {
+			return writeProfile(profiler, *profile)
+		}
+		return nil
+
+	case "debug":
+		if len(args) != 2 {
+			return fmt.Errorf("expected a single script file\n%s", usage)
+		}
+		return debugFile(args[1], os.Stdin, os.Stdout)
+
+	case "repl":
+		return runRepl()
+
+	case "lsp":
+		return lsp.NewServer(newDemoLanguage()).Serve(os.Stdin, os.Stdout)
+
+	case "dap":
+		newContext := func(output io.Writer) *demoContext { return &demoContext{out: output} }
+		return dap.NewServer(newDemoLanguage(), newContext).Serve(os.Stdin, os.Stdout)
+
+	default:
+		return fmt.Errorf("unknown command %s\n%s", args[0], usage)
+	}
+}
+
+// runRepl starts an interactive session on the standard input and output.
+func runRepl() error {
+	newContext := func() *demoContext { return &demoContext{out: os.Stdout} }
+	return repl.New(newDemoLanguage(), newContext).Run(os.Stdin, os.Stdout)
+}
+
+// writeProfile writes the profile report to the standard error and the pprof profile to the given path.
+func writeProfile(profiler *pala.Profiler, path string) error {
+	if err := profiler.WriteReport(os.Stderr); err != nil {
+		return err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := profiler.WritePprof(file); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,169 @@
+package pala
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// conversion identifies a converter by the types it converts between.
+type conversion struct {
+	from reflect.Type
+	to   reflect.Type
+}
+
+// converter converts a value to the target type of its conversion.
+type converter func(value reflect.Value) (reflect.Value, error)
+
+// BindConverter adds a converter to the language, which is applied when an operand, list element or tuple element of
+// one type is given where another type is expected.
+// It must be provided with a function with signature `func(A) (B, error)`. When the function fails, the run is stopped
+// with a *RuntimeError at the position of the converted expression.
+// Converters bound to the language are preferred over the built-in numeric widening.
+func (l *Language[C]) BindConverter(converter interface{}) {
+	funcValue := reflect.ValueOf(converter)
+
+	if funcValue.Kind() != reflect.Func {
+		panic("function is required")
+	}
+
+	funcType := funcValue.Type()
+
+	if funcType.NumIn() != 1 ||
+		funcType.NumOut() != 2 ||
+		funcType.Out(1) != errorType {
+		panic("function must have signature func(A) (B, error)")
+	}
+
+	l.converters[conversion{from: funcType.In(0), to: funcType.Out(0)}] = func(value reflect.Value) (reflect.Value, error) {
+		results := funcValue.Call([]reflect.Value{value})
+		if err := results[1].Interface(); err != nil {
+			return reflect.Value{}, err.(error)
+		}
+		return results[0], nil
+	}
+}
+
+// lookupConverter returns the converter from one type to another that is bound to the language or the languages it
+// extends, falling back to numeric widening.
+func (l *Language[C]) lookupConverter(from, to reflect.Type) (converter, bool) {
+	for language := l; language != nil; language = language.parent {
+		if convert, has := language.converters[conversion{from: from, to: to}]; has {
+			return convert, true
+		}
+	}
+	if widens(from, to) {
+		return func(value reflect.Value) (reflect.Value, error) { return value.Convert(to), nil }, true
+	}
+	return nil, false
+}
+
+// allConverters returns the converters of the language, including those of the languages it extends.
+func (l *Language[C]) allConverters() map[conversion]converter {
+	converters := make(map[conversion]converter)
+	if l.parent != nil {
+		converters = l.parent.allConverters()
+	}
+	for key, convert := range l.converters {
+		converters[key] = convert
+	}
+	return converters
+}
+
+// coerce converts a node to the given type with a converter. Slices are converted element by element, for instance
+// from []int to []any or []float64.
+func (l *Language[C]) coerce(node astNode[C], targetType reflect.Type) (astNode[C], bool) {
+	if node.returnType == nil {
+		return astNode[C]{}, false
+	}
+
+	var convert converter
+	if found, has := l.lookupConverter(node.returnType, targetType); has {
+		convert = found
+	} else if node.returnType.Kind() == reflect.Slice && targetType.Kind() == reflect.Slice {
+		convert = l.sliceConverter(node.returnType.Elem(), targetType)
+	}
+	if convert == nil {
+		return astNode[C]{}, false
+	}
+
+	converted := conversionNode(node, targetType, convert)
+	converted.start, converted.end, converted.children = node.start, node.end, node.children
+	return converted, true
+}
+
+// sliceConverter returns a converter of slices with elements of the given type to the target slice type, or nil if the
+// elements cannot be converted.
+func (l *Language[C]) sliceConverter(elementType reflect.Type, targetType reflect.Type) converter {
+	targetElement := targetType.Elem()
+	convertElement, has := l.lookupConverter(elementType, targetElement)
+	if !has {
+		if targetElement.Kind() != reflect.Interface || !elementType.Implements(targetElement) {
+			return nil
+		}
+		convertElement = func(value reflect.Value) (reflect.Value, error) { return value, nil }
+	}
+
+	return func(value reflect.Value) (reflect.Value, error) {
+		result := reflect.MakeSlice(targetType, value.Len(), value.Len())
+		for i := 0; i < value.Len(); i++ {
+			element, err := convertElement(value.Index(i))
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			result.Index(i).Set(element)
+		}
+		return result, nil
+	}
+}
+
+// conversionNode creates an astNode that converts the value of the given node to the target type.
+func conversionNode[C any](node astNode[C], targetType reflect.Type, convert converter) astNode[C] {
+	return astNode[C]{
+		returnType: targetType,
+		evaluate: func(rt *runtime[C]) interface{} {
+			value := reflect.ValueOf(node.evaluate(rt))
+			if !value.IsValid() {
+				return reflect.Zero(targetType).Interface()
+			}
+			if value.Kind() == reflect.Slice && targetType.Kind() == reflect.Slice {
+				rt.allocate(node.start, value.Len())
+			}
+			converted, err := convert(value)
+			if err != nil {
+				panic(abort{err: &RuntimeError{Pos: node.start, Msg: fmt.Sprintf("cannot convert %v to %s: %s", value, targetType, err)}})
+			}
+			return converted.Interface()
+		},
+	}
+}
+
+// widens reports whether a value of one predeclared numeric type can be converted to another without losing range.
+// Integers also widen to float64, and integers of at most 16 bits to float32.
+func widens(from, to reflect.Type) bool {
+	if from == to || from.PkgPath() != "" || to.PkgPath() != "" {
+		return false
+	}
+
+	switch {
+	case isSignedKind(from.Kind()) && isSignedKind(to.Kind()):
+		return to.Bits() > from.Bits() || to.Kind() == reflect.Int64
+	case isUnsignedKind(from.Kind()) && isUnsignedKind(to.Kind()):
+		return to.Bits() > from.Bits() || to.Kind() == reflect.Uint64
+	case isUnsignedKind(from.Kind()) && isSignedKind(to.Kind()):
+		return to.Bits() > from.Bits()
+	case isSignedKind(from.Kind()) || isUnsignedKind(from.Kind()):
+		return to.Kind() == reflect.Float64 || (to.Kind() == reflect.Float32 && from.Bits() <= 16)
+	default:
+		return from.Kind() == reflect.Float32 && to.Kind() == reflect.Float64
+	}
+}
+
+func isSignedKind(kind reflect.Kind) bool {
+	return kind >= reflect.Int && kind <= reflect.Int64
+}
+
+func isUnsignedKind(kind reflect.Kind) bool {
+	return kind >= reflect.Uint && kind <= reflect.Uint64
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
@@ -0,0 +1,130 @@
+package pala
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type celsius float64
+
+func parseFloat(s string) (float64, error) {
+	return strconv.ParseFloat(s, 64)
+}
+
+func TestLanguage_Conversions(t *testing.T) {
+	lang := NewLanguage[*context]()
+	lang.BindOperator("half", func(f float64) float64 { return f / 2 })
+	lang.BindOperator("big", func(i int64) int64 { return i * 1000 })
+	lang.BindOperator("small", func(i int8) int8 { return i })
+	lang.BindOperator("sum", func(fs []float64) float64 {
+		total := 0.0
+		for _, f := range fs {
+			total += f
+		}
+		return total
+	})
+	lang.BindOperator("count", func(xs []any) int { return len(xs) })
+	lang.BindOperator("ints", func() []int { return []int{1, 2, 3} })
+	lang.BindOperator("wait", func(d time.Duration) string { return d.String() })
+	lang.BindOperator("warm", func(c celsius) bool { return c > 20 })
+	lang.BindConverter(time.ParseDuration)
+	lang.BindConverter(func(f float64) (celsius, error) {
+		if f < -273.15 {
+			return 0, fmt.Errorf("below absolute zero")
+		}
+		return celsius(f), nil
+	})
+	lang.BindLiteralEvaluator(ParseInt)
+	lang.BindLiteralEvaluator(parseFloat)
+	lang.BindLiteralEvaluator(ParseString)
+
+	tests := []evalCase{
+		{"int to float64", "half 3", 1.5, ""},
+		{"int to int64", "big 2", int64(2000), ""},
+		{"no narrowing", "small 2", nil, "[line 0] operand 0 of operator small expects int8 but got int"},
+		{"no float to int", "big 2.5", nil, `[line 0] operand 0 of operator big expects int64 but got float64 (pala.ParseInt: strconv.ParseInt: parsing "2.5": invalid syntax)`},
+		{"list elements", "sum [1 2 3]", 6.0, ""},
+		{"mixed numeric list", "sum [1 2.5]", 3.5, ""},
+		{"unified list variable", "$xs [1 2.5 3]\nsum $xs", 6.5, ""},
+		{"slice to []any", "$xs ints\ncount $xs", 3, ""},
+		{"slice to []float64", "$xs ints\nsum $xs", 6.0, ""},
+		{"user converter", "wait 1m30s", "1m30s", ""},
+		{"user converter to named type", "warm 25.5", true, ""},
+		{"failing converter", "wait soon", nil, "[line 0] cannot convert soon to time.Duration: time: invalid duration \"soon\""},
+		{"converter error with value", "warm -300.0", nil, "[line 0] cannot convert -300 to pala.celsius: below absolute zero"},
+		{"literal evaluated for converter", "warm 25", true, ""},
+		{"no conversion chain", "$n big 1\nwarm $n", nil, "[line 1] operand 0 of operator warm expects pala.celsius but got int64"},
+		{"mixed list without conversion", "sum [1 a]", nil, "[line 0] list must contain a single type"},
+	}
+
+	runCases(t, lang, tests)
+}
+
+func TestLanguage_ConverterError(t *testing.T) {
+	lang := NewLanguage[*context]()
+	lang.BindOperator("wait", func(d time.Duration) time.Duration { return d })
+	lang.BindConverter(time.ParseDuration)
+	lang.BindLiteralEvaluator(ParseString)
+
+	prog, err := NewParser(NewLexer(strings.NewReader("wait 1s\nwait later")), lang).Parse()
+	if err != nil {
+		t.Fatalf("expected program to be parsed:\n%s", err)
+	}
+
+	err = prog.Run(&context{})
+	var runtimeErr *RuntimeError
+	if !errors.As(err, &runtimeErr) || runtimeErr.Pos != (Position{Line: 1, Col: 5}) {
+		t.Errorf("expected runtime error at the literal but got %v", err)
+	}
+}
+
+func TestLanguage_ImportConverters(t *testing.T) {
+	units := NewLanguage[*context]()
+	units.BindConverter(time.ParseDuration)
+
+	lang := NewLanguage[*context]()
+	lang.Import(units)
+	lang.BindOperator("wait", func(d time.Duration) time.Duration { return d })
+	lang.BindLiteralEvaluator(ParseString)
+
+	if value := evalWith(t, lang.Extend(), "wait 2s"); value != 2*time.Second {
+		t.Errorf("expected 2s but got %v", value)
+	}
+}
+
+func TestWidens(t *testing.T) {
+	tests := []struct {
+		from     interface{}
+		to       interface{}
+		expected bool
+	}{
+		{int(0), int64(0), true},
+		{int64(0), int(0), false},
+		{int8(0), int16(0), true},
+		{int32(0), int8(0), false},
+		{uint8(0), uint32(0), true},
+		{uint8(0), int16(0), true},
+		{uint64(0), int64(0), false},
+		{int64(0), uint64(0), false},
+		{int(0), float64(0), true},
+		{int16(0), float32(0), true},
+		{int32(0), float32(0), false},
+		{float32(0), float64(0), true},
+		{float64(0), float32(0), false},
+		{float64(0), int64(0), false},
+		{int64(0), time.Duration(0), false},
+		{"", int(0), false},
+	}
+
+	for _, tt := range tests {
+		from, to := reflect.TypeOf(tt.from), reflect.TypeOf(tt.to)
+		if widens(from, to) != tt.expected {
+			t.Errorf("expected widening from %s to %s to be %t", from, to, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+
+	"github.com/RoelofRuis/pala/internal/wire"
+)
+
+// The subset of the Debug Adapter Protocol that is implemented by the server.
+// See https://microsoft.github.io/debug-adapter-protocol/specification
+
+type message struct {
+	Seq        int             `json:"seq"`
+	Type       string          `json:"type"`
+	Command    string          `json:"command,omitempty"`
+	Arguments  json.RawMessage `json:"arguments,omitempty"`
+	RequestSeq int             `json:"request_seq,omitempty"`
+	Success    *bool           `json:"success,omitempty"`
+	Message    string          `json:"message,omitempty"`
+	Event      string          `json:"event,omitempty"`
+	Body       interface{}     `json:"body,omitempty"`
+}
+
+type source struct {
+	Name string `json:"name,omitempty"`
+	Path string `json:"path,omitempty"`
+}
+
+type launchArguments struct {
+	Program     string `json:"program"`
+	StopOnEntry bool   `json:"stopOnEntry"`
+}
+
+type setBreakpointsArguments struct {
+	Source      source `json:"source"`
+	Breakpoints []struct {
+		Line int `json:"line"`
+	} `json:"breakpoints"`
+}
+
+type breakpoint struct {
+	Verified bool `json:"verified"`
+	Line     int  `json:"line"`
+}
+
+type thread struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type stackFrame struct {
+	ID     int    `json:"id"`
+	Name   string `json:"name"`
+	Source source `json:"source"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+}
+
+type scope struct {
+	Name               string `json:"name"`
+	VariablesReference int    `json:"variablesReference"`
+	Expensive          bool   `json:"expensive"`
+}
+
+type variable struct {
+	Name               string `json:"name"`
+	Value              string `json:"value"`
+	Type               string `json:"type,omitempty"`
+	VariablesReference int    `json:"variablesReference"`
+}
+
+type stoppedEvent struct {
+	Reason            string `json:"reason"`
+	ThreadID          int    `json:"threadId"`
+	AllThreadsStopped bool   `json:"allThreadsStopped"`
+}
+
+type outputEvent struct {
+	Category string `json:"category"`
+	Output   string `json:"output"`
+}
+
+type exitedEvent struct {
+	ExitCode int `json:"exitCode"`
+}
+
+const (
+	// The server runs a single program, which is reported as a single thread with a single stack frame.
+	threadID             = 1
+	frameID              = 1
+	variablesReferenceID = 1
+)
+
+func readMessage(r *bufio.Reader) (message, error) {
+	body, err := wire.Read(r)
+	if err != nil {
+		return message{}, err
+	}
+
+	var msg message
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return message{}, err
+	}
+	return msg, nil
+}
+
+func writeMessage(w io.Writer, msg message) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return wire.Write(w, body)
+}
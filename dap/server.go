@@ -0,0 +1,364 @@
+// Package dap implements a Debug Adapter Protocol server for scripts written in a pala Language.
+//
+// The server communicates over a single reader and writer, such as the standard input and output of a process. It
+// launches a single script, supports line breakpoints and stepping, and shows the variables of the script as a scope.
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/RoelofRuis/pala"
+)
+
+// Server is a debug adapter for a Language.
+type Server[C any] struct {
+	language   *pala.Language[C]
+	newContext func(output io.Writer) C
+
+	mutex       sync.Mutex
+	out         io.Writer
+	seq         int
+	program     string
+	variables   []pala.VariableInfo
+	debugger    *pala.Debugger[C]
+	breakpoints map[string][]int // Lines of the breakpoints per source path.
+	stop        *pala.Stop       // nil while the program is running.
+	resumption  func(d *pala.Debugger[C])
+	finished    chan struct{}
+}
+
+// NewServer constructs a debug adapter for the given language.
+// The context factory is called when a script is launched. Everything the script writes to the given output is sent
+// to the client as output events.
+func NewServer[C any](language *pala.Language[C], newContext func(output io.Writer) C) *Server[C] {
+	return &Server[C]{
+		language:   language,
+		newContext: newContext,
+	}
+}
+
+// Serve handles requests read from in and writes the responses and events to out, until the client disconnects or in
+// is closed.
+func (s *Server[C]) Serve(in io.Reader, out io.Writer) error {
+	s.out = out
+	reader := bufio.NewReader(in)
+	for {
+		msg, err := readMessage(reader)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if msg.Type != "request" {
+			continue
+		}
+
+		body, err := s.handle(msg)
+		if writeErr := s.respond(msg, body, err); writeErr != nil {
+			return writeErr
+		}
+
+		// Follow-up actions are only taken after the response is sent, so that the client receives the events that
+		// they cause after the response.
+		switch msg.Command {
+		case "initialize":
+			s.sendEvent("initialized", nil)
+		case "configurationDone":
+			s.start()
+		case "disconnect":
+			if s.resume() {
+				// The program was aborted, wait for it to report that it has ended.
+				<-s.finished
+			}
+			return nil
+		}
+		s.resume()
+	}
+}
+
+func (s *Server[C]) handle(msg message) (interface{}, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	switch msg.Command {
+	case "initialize":
+		return map[string]interface{}{
+			"supportsConfigurationDoneRequest": true,
+		}, nil
+
+	case "launch":
+		var args launchArguments
+		if err := json.Unmarshal(msg.Arguments, &args); err != nil {
+			return nil, err
+		}
+		return nil, s.launch(args)
+
+	case "setBreakpoints":
+		var args setBreakpointsArguments
+		if err := json.Unmarshal(msg.Arguments, &args); err != nil {
+			return nil, err
+		}
+		return s.setBreakpoints(args), nil
+
+	case "configurationDone":
+		if s.debugger == nil {
+			return nil, fmt.Errorf("no program was launched")
+		}
+		return nil, nil
+
+	case "threads":
+		return map[string]interface{}{"threads": []thread{{ID: threadID, Name: "main"}}}, nil
+
+	case "stackTrace":
+		return s.stackTrace(), nil
+
+	case "scopes":
+		return map[string]interface{}{
+			"scopes": []scope{{Name: "Variables", VariablesReference: variablesReferenceID}},
+		}, nil
+
+	case "variables":
+		return map[string]interface{}{"variables": s.variableList()}, nil
+
+	case "continue":
+		return map[string]interface{}{"allThreadsContinued": true}, s.scheduleResume((*pala.Debugger[C]).Continue)
+
+	case "next":
+		return nil, s.scheduleResume((*pala.Debugger[C]).StepOver)
+
+	case "stepIn":
+		return nil, s.scheduleResume((*pala.Debugger[C]).StepInto)
+
+	case "stepOut":
+		return nil, s.scheduleResume((*pala.Debugger[C]).StepOut)
+
+	case "disconnect", "terminate":
+		if s.stop != nil {
+			_ = s.scheduleResume((*pala.Debugger[C]).Quit)
+		}
+		return nil, nil
+
+	default:
+		return nil, fmt.Errorf("request %s is not supported", msg.Command)
+	}
+}
+
+// launch parses the program but does not start it yet, to allow the client to set breakpoints first.
+func (s *Server[C]) launch(args launchArguments) error {
+	if s.debugger != nil {
+		return fmt.Errorf("a program was already launched")
+	}
+
+	program := filepath.Clean(args.Program)
+	file, err := os.Open(program)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	lexer := pala.NewLexer(bufio.NewReader(file), pala.WithSource(program))
+	parser := pala.NewParser(lexer, s.language, pala.WithImportFS(os.DirFS(filepath.Dir(program))))
+	prog, err := parser.Parse()
+	if err != nil {
+		return err
+	}
+
+	s.program = program
+	s.variables = parser.Variables()
+	s.debugger = pala.NewDebugger(prog)
+	s.debugger.SetStopOnEntry(args.StopOnEntry)
+	for sourcePath, lines := range s.breakpoints {
+		for _, line := range lines {
+			s.debugger.SetBreakpoint(pala.Breakpoint{Source: sourcePath, Line: line})
+		}
+	}
+	return nil
+}
+
+// setBreakpoints replaces the breakpoints of a source. Lines of the protocol start at 1, while pala lines start at 0.
+// Sources are identified by their path, which matches the source of the positions in the launched program and in the
+// files it imports.
+func (s *Server[C]) setBreakpoints(args setBreakpointsArguments) interface{} {
+	sourcePath := filepath.Clean(args.Source.Path)
+	if s.debugger != nil {
+		for _, line := range s.breakpoints[sourcePath] {
+			s.debugger.ClearBreakpoints(pala.SourceLine{Source: sourcePath, Line: line})
+		}
+	}
+
+	if s.breakpoints == nil {
+		s.breakpoints = make(map[string][]int)
+	}
+	s.breakpoints[sourcePath] = nil
+	breakpoints := []breakpoint{}
+	for _, bp := range args.Breakpoints {
+		line := bp.Line - 1
+		s.breakpoints[sourcePath] = append(s.breakpoints[sourcePath], line)
+		if s.debugger != nil {
+			s.debugger.SetBreakpoint(pala.Breakpoint{Source: sourcePath, Line: line})
+		}
+		breakpoints = append(breakpoints, breakpoint{Verified: true, Line: bp.Line})
+	}
+	return map[string]interface{}{"breakpoints": breakpoints}
+}
+
+func (s *Server[C]) stackTrace() interface{} {
+	frames := []stackFrame{}
+	if s.stop != nil {
+		name := "statement"
+		if s.stop.Event.Kind == pala.TraceOperator {
+			name = s.stop.Event.Symbol
+		}
+		sourcePath := s.stop.Event.Pos.Source
+		if sourcePath == "" {
+			sourcePath = s.program
+		}
+		frames = append(frames, stackFrame{
+			ID:     frameID,
+			Name:   name,
+			Source: source{Name: filepath.Base(sourcePath), Path: sourcePath},
+			Line:   s.stop.Event.Pos.Line + 1,
+			Column: s.stop.Event.Pos.Col + 1,
+		})
+	}
+	return map[string]interface{}{"stackFrames": frames, "totalFrames": len(frames)}
+}
+
+func (s *Server[C]) variableList() []variable {
+	variables := []variable{}
+	if s.stop == nil {
+		return variables
+	}
+
+	types := make(map[string]string)
+	for _, info := range s.variables {
+		if info.Type != nil {
+			types[info.Name] = info.Type.String()
+		}
+	}
+
+	for name, value := range s.stop.Variables {
+		variables = append(variables, variable{Name: name, Value: fmt.Sprint(value), Type: types[name]})
+	}
+	sort.Slice(variables, func(i, j int) bool { return variables[i].Name < variables[j].Name })
+	return variables
+}
+
+// scheduleResume prepares to resume a paused program with the given debugger command.
+func (s *Server[C]) scheduleResume(command func(d *pala.Debugger[C])) error {
+	if s.stop == nil {
+		return fmt.Errorf("the program is not paused")
+	}
+	s.stop = nil
+	s.resumption = command
+	return nil
+}
+
+// resume resumes the program if this was scheduled. It reports whether the program was resumed.
+func (s *Server[C]) resume() bool {
+	s.mutex.Lock()
+	command := s.resumption
+	s.resumption = nil
+	s.mutex.Unlock()
+
+	if command == nil {
+		return false
+	}
+	command(s.debugger)
+	return true
+}
+
+// start runs the launched program and reports every stop to the client.
+func (s *Server[C]) start() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.debugger == nil || s.finished != nil {
+		return
+	}
+
+	s.finished = make(chan struct{})
+	s.debugger.Start(s.newContext(outputWriter[C]{s}))
+
+	go func() {
+		defer close(s.finished)
+		for {
+			stop, paused := s.debugger.Wait()
+			if !paused {
+				break
+			}
+
+			s.mutex.Lock()
+			s.stop = &stop
+			s.mutex.Unlock()
+
+			s.sendEvent("stopped", stoppedEvent{Reason: stopReason(stop.Reason), ThreadID: threadID, AllThreadsStopped: true})
+		}
+
+		exitCode := 0
+		if err := s.debugger.Err(); err != nil {
+			exitCode = 1
+			if !errors.Is(err, pala.ErrAborted) {
+				s.sendEvent("output", outputEvent{Category: "stderr", Output: err.Error() + "\n"})
+			}
+		}
+		s.sendEvent("exited", exitedEvent{ExitCode: exitCode})
+		s.sendEvent("terminated", nil)
+	}()
+}
+
+func (s *Server[C]) respond(request message, body interface{}, err error) error {
+	success := err == nil
+	response := message{
+		Type:       "response",
+		RequestSeq: request.Seq,
+		Command:    request.Command,
+		Success:    &success,
+		Body:       body,
+	}
+	if err != nil {
+		response.Message = err.Error()
+	}
+	return s.send(response)
+}
+
+func (s *Server[C]) sendEvent(event string, body interface{}) {
+	_ = s.send(message{Type: "event", Event: event, Body: body})
+}
+
+func (s *Server[C]) send(msg message) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.seq++
+	msg.Seq = s.seq
+	return writeMessage(s.out, msg)
+}
+
+func stopReason(reason pala.StopReason) string {
+	switch reason {
+	case pala.StopEntry:
+		return "entry"
+	case pala.StopBreakpoint:
+		return "breakpoint"
+	default:
+		return "step"
+	}
+}
+
+// outputWriter sends everything that is written to it to the client as output events.
+type outputWriter[C any] struct {
+	server *Server[C]
+}
+
+func (w outputWriter[C]) Write(p []byte) (int, error) {
+	w.server.sendEvent("output", outputEvent{Category: "stdout", Output: string(p)})
+	return len(p), nil
+}
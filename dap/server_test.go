@@ -0,0 +1,240 @@
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/RoelofRuis/pala"
+	"github.com/RoelofRuis/pala/internal/wire"
+)
+
+type context struct {
+	out io.Writer
+}
+
+func newTestLanguage() *pala.Language[*context] {
+	lang := pala.NewLanguage[*context]()
+	lang.BindOperator("+", func(a, b int) int { return a + b })
+	lang.BindOperator("echo", func(c *context, a int) { _, _ = fmt.Fprintln(c.out, a) })
+	lang.BindLiteralEvaluator(pala.ParseInt)
+	return lang
+}
+
+// client sends requests to a server and receives its messages.
+type client struct {
+	t        *testing.T
+	in       *io.PipeWriter
+	seq      int
+	messages chan message
+}
+
+func newClient(t *testing.T) *client {
+	inReader, inWriter := io.Pipe()
+	outReader, outWriter := io.Pipe()
+
+	server := NewServer(newTestLanguage(), func(output io.Writer) *context { return &context{out: output} })
+	go func() {
+		_ = server.Serve(inReader, outWriter)
+		_ = outWriter.Close()
+	}()
+
+	messages := make(chan message, 100)
+	go func() {
+		reader := bufio.NewReader(outReader)
+		for {
+			msg, err := readMessage(reader)
+			if err != nil {
+				close(messages)
+				return
+			}
+			messages <- msg
+		}
+	}()
+
+	return &client{t: t, in: inWriter, messages: messages}
+}
+
+func (c *client) send(command string, arguments interface{}) {
+	c.seq++
+	args, _ := json.Marshal(arguments)
+	body, _ := json.Marshal(message{Seq: c.seq, Type: "request", Command: command, Arguments: args})
+	if err := wire.Write(c.in, body); err != nil {
+		c.t.Fatalf("expected request to be sent: %s", err)
+	}
+}
+
+// expect returns the body of the next message, which must be the given response or event. The body is encoded with
+// sorted keys.
+func (c *client) expect(kind, name string) string {
+	select {
+	case msg, ok := <-c.messages:
+		if !ok {
+			c.t.Fatalf("expected %s %s but the server stopped", kind, name)
+		}
+		if msg.Type != kind || (msg.Command != name && msg.Event != name) {
+			c.t.Fatalf("expected %s %s but got %+v", kind, name, msg)
+		}
+		if kind == "response" && !*msg.Success {
+			c.t.Fatalf("expected response %s to succeed but got: %s", name, msg.Message)
+		}
+		body, _ := json.Marshal(msg.Body)
+		return string(body)
+	case <-time.After(time.Second):
+		c.t.Fatalf("expected %s %s but got nothing", kind, name)
+		return ""
+	}
+}
+
+func writeScript(t *testing.T, script string) string {
+	path := filepath.Join(t.TempDir(), "script.pala")
+	if err := os.WriteFile(path, []byte(script), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestServer_Session(t *testing.T) {
+	path := writeScript(t, "$a + 1 2\n$b + $a 3\necho $b\n")
+	c := newClient(t)
+
+	c.send("initialize", map[string]string{"adapterID": "pala"})
+	c.expect("response", "initialize")
+	c.expect("event", "initialized")
+
+	c.send("launch", map[string]interface{}{"program": path})
+	c.expect("response", "launch")
+
+	c.send("setBreakpoints", map[string]interface{}{
+		"source":      map[string]string{"path": path},
+		"breakpoints": []map[string]int{{"line": 2}},
+	})
+	if body := c.expect("response", "setBreakpoints"); body != `{"breakpoints":[{"line":2,"verified":true}]}` {
+		t.Errorf("unexpected breakpoints %s", body)
+	}
+
+	c.send("configurationDone", nil)
+	c.expect("response", "configurationDone")
+	if body := c.expect("event", "stopped"); body != `{"allThreadsStopped":true,"reason":"breakpoint","threadId":1}` {
+		t.Errorf("unexpected stop %s", body)
+	}
+
+	c.send("stackTrace", map[string]int{"threadId": 1})
+	expectedFrames := fmt.Sprintf(`{"stackFrames":[{"column":1,"id":1,"line":2,"name":"statement","source":{"name":"script.pala","path":"%s"}}],"totalFrames":1}`, path)
+	if body := c.expect("response", "stackTrace"); body != expectedFrames {
+		t.Errorf("unexpected stack trace %s", body)
+	}
+
+	c.send("scopes", map[string]int{"frameId": 1})
+	c.expect("response", "scopes")
+
+	c.send("variables", map[string]int{"variablesReference": 1})
+	if body := c.expect("response", "variables"); body != `{"variables":[{"name":"$a","type":"int","value":"3","variablesReference":0}]}` {
+		t.Errorf("unexpected variables %s", body)
+	}
+
+	c.send("stepIn", map[string]int{"threadId": 1})
+	c.expect("response", "stepIn")
+	c.expect("event", "stopped")
+
+	c.send("stackTrace", map[string]int{"threadId": 1})
+	expectedFrames = fmt.Sprintf(`{"stackFrames":[{"column":4,"id":1,"line":2,"name":"+","source":{"name":"script.pala","path":"%s"}}],"totalFrames":1}`, path)
+	if body := c.expect("response", "stackTrace"); body != expectedFrames {
+		t.Errorf("unexpected stack trace %s", body)
+	}
+
+	c.send("continue", map[string]int{"threadId": 1})
+	c.expect("response", "continue")
+	if body := c.expect("event", "output"); body != `{"category":"stdout","output":"6\n"}` {
+		t.Errorf("unexpected output %s", body)
+	}
+	if body := c.expect("event", "exited"); body != `{"exitCode":0}` {
+		t.Errorf("unexpected exit %s", body)
+	}
+	c.expect("event", "terminated")
+
+	c.send("disconnect", nil)
+	c.expect("response", "disconnect")
+}
+
+func TestServer_ImportedSource(t *testing.T) {
+	path := writeScript(t, "$a + 1 2\nimport lib.pala\necho $b\n")
+	lib := filepath.Join(filepath.Dir(path), "lib.pala")
+	if err := os.WriteFile(lib, []byte("$b + $a 3\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c := newClient(t)
+
+	c.send("initialize", nil)
+	c.expect("response", "initialize")
+	c.expect("event", "initialized")
+	c.send("launch", map[string]interface{}{"program": path})
+	c.expect("response", "launch")
+
+	// Line 1 of the script is not paused at, as the breakpoint is set in the imported file.
+	c.send("setBreakpoints", map[string]interface{}{
+		"source":      map[string]string{"path": lib},
+		"breakpoints": []map[string]int{{"line": 1}},
+	})
+	c.expect("response", "setBreakpoints")
+	c.send("configurationDone", nil)
+	c.expect("response", "configurationDone")
+	c.expect("event", "stopped")
+
+	c.send("stackTrace", map[string]int{"threadId": 1})
+	expectedFrames := fmt.Sprintf(`{"stackFrames":[{"column":1,"id":1,"line":1,"name":"statement","source":{"name":"lib.pala","path":"%s"}}],"totalFrames":1}`, lib)
+	if body := c.expect("response", "stackTrace"); body != expectedFrames {
+		t.Errorf("unexpected stack trace %s", body)
+	}
+
+	c.send("continue", map[string]int{"threadId": 1})
+	c.expect("response", "continue")
+	if body := c.expect("event", "output"); body != `{"category":"stdout","output":"6\n"}` {
+		t.Errorf("unexpected output %s", body)
+	}
+	c.expect("event", "exited")
+	c.expect("event", "terminated")
+	c.send("disconnect", nil)
+	c.expect("response", "disconnect")
+}
+
+func TestServer_Disconnect(t *testing.T) {
+	path := writeScript(t, "$a + 1 2\necho $a\n")
+	c := newClient(t)
+
+	c.send("initialize", nil)
+	c.expect("response", "initialize")
+	c.expect("event", "initialized")
+
+	c.send("launch", map[string]interface{}{"program": path, "stopOnEntry": true})
+	c.expect("response", "launch")
+
+	c.send("configurationDone", nil)
+	c.expect("response", "configurationDone")
+	if body := c.expect("event", "stopped"); body != `{"allThreadsStopped":true,"reason":"entry","threadId":1}` {
+		t.Errorf("unexpected stop %s", body)
+	}
+
+	c.send("disconnect", nil)
+	c.expect("response", "disconnect")
+	if body := c.expect("event", "exited"); body != `{"exitCode":1}` {
+		t.Errorf("unexpected exit %s", body)
+	}
+	c.expect("event", "terminated")
+}
+
+func TestServer_LaunchInvalidScript(t *testing.T) {
+	path := writeScript(t, "+ 1")
+	c := newClient(t)
+
+	c.send("launch", map[string]interface{}{"program": path})
+	msg := <-c.messages
+	if *msg.Success || msg.Message != path+":1:1: operator + expected 2 operands but got 1" {
+		t.Errorf("expected launch to fail but got %+v", msg)
+	}
+}
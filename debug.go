@@ -0,0 +1,220 @@
+package pala
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Debugger runs a program in the background and pauses it before statements and operator calls, so that the
+// variables of the program can be inspected.
+//
+// A debugging session consists of calling Start, followed by calls to Wait. Every time Wait returns a Stop the program
+// is paused, and it remains paused until one of Continue, StepInto, StepOver, StepOut or Quit is called.
+// Wait returns false once the program has finished, after which Err returns the result of the run.
+type Debugger[C any] struct {
+	program Program[C]
+
+	mutex       sync.Mutex
+	breakpoints map[SourceLine][]Breakpoint
+	stopOnEntry bool
+
+	stops    chan Stop
+	commands chan debugCommand
+	err      error
+
+	// The fields below are only accessed by the goroutine that runs the program.
+	atEntry   bool
+	mode      debugCommand
+	stepDepth int
+	pos       Position // Position of the last statement or operator call that was entered.
+}
+
+// Breakpoint pauses the program before a statement that starts on the given line of the given source. The source is
+// the name given to the lexer with WithSource, or the path of an imported file, and is empty for a lexer without it.
+// If a condition is given, the program only pauses if the condition holds for the current variables.
+type Breakpoint struct {
+	Source    string
+	Line      int
+	Condition func(variables map[string]interface{}) bool
+}
+
+// StopReason describes why the debugger paused the program.
+type StopReason int
+
+const (
+	StopEntry StopReason = iota
+	StopStep
+	StopBreakpoint
+)
+
+// Stop describes the point at which the program is paused.
+// Event describes the statement or operator call that is about to be evaluated. Operands of an operator call have
+// already been evaluated. Variables holds a copy of the program variables.
+type Stop struct {
+	Reason    StopReason
+	Event     TraceEvent
+	Variables map[string]interface{}
+}
+
+type debugCommand int
+
+const (
+	debugContinue debugCommand = iota
+	debugStepInto
+	debugStepOver
+	debugStepOut
+	debugQuit
+)
+
+// NewDebugger constructs a debugger for the given program.
+func NewDebugger[C any](program Program[C]) *Debugger[C] {
+	return &Debugger[C]{
+		program:     program,
+		breakpoints: make(map[SourceLine][]Breakpoint),
+	}
+}
+
+// SetBreakpoint adds a breakpoint. Breakpoints can be set before the program is started or while it is paused.
+func (d *Debugger[C]) SetBreakpoint(breakpoint Breakpoint) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	line := SourceLine{Source: breakpoint.Source, Line: breakpoint.Line}
+	d.breakpoints[line] = append(d.breakpoints[line], breakpoint)
+}
+
+// ClearBreakpoints removes all breakpoints on the given line.
+func (d *Debugger[C]) ClearBreakpoints(line SourceLine) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	delete(d.breakpoints, line)
+}
+
+// SetStopOnEntry determines whether the program is paused before its first statement.
+func (d *Debugger[C]) SetStopOnEntry(stopOnEntry bool) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.stopOnEntry = stopOnEntry
+}
+
+// Start runs the program in the background with the given context.
+func (d *Debugger[C]) Start(context C, options ...RunOption) {
+	d.stops = make(chan Stop)
+	d.commands = make(chan debugCommand)
+	d.mode = debugContinue
+
+	d.mutex.Lock()
+	d.atEntry = d.stopOnEntry
+	d.mutex.Unlock()
+
+	options = append(options, WithTracer(debugTracer[C]{d}))
+	go func() {
+		defer close(d.stops)
+		defer func() {
+			// An operator that panics stops the run instead of the host process.
+			if recovered := recover(); recovered != nil {
+				d.err = &RuntimeError{Pos: d.pos, Msg: fmt.Sprint(recovered)}
+			}
+		}()
+		d.err = d.program.Run(context, options...)
+	}()
+}
+
+// Wait blocks until the program is paused or has finished. It returns false if the program has finished.
+// If an operator panics, the run finishes with a *RuntimeError at the position of the statement or operator call that
+// was entered last.
+func (d *Debugger[C]) Wait() (Stop, bool) {
+	stop, ok := <-d.stops
+	return stop, ok
+}
+
+// Err returns the error of the run, once Wait has reported that the program has finished.
+func (d *Debugger[C]) Err() error {
+	return d.err
+}
+
+// Continue resumes the program until the next breakpoint.
+func (d *Debugger[C]) Continue() {
+	d.commands <- debugContinue
+}
+
+// StepInto resumes the program until the next statement or operator call.
+func (d *Debugger[C]) StepInto() {
+	d.commands <- debugStepInto
+}
+
+// StepOver resumes the program until the next statement or operator call that is not nested in the current one.
+func (d *Debugger[C]) StepOver() {
+	d.commands <- debugStepOver
+}
+
+// StepOut resumes the program until the next statement or operator call outside the current one.
+func (d *Debugger[C]) StepOut() {
+	d.commands <- debugStepOut
+}
+
+// Quit aborts the program. The run ends with ErrAborted.
+func (d *Debugger[C]) Quit() {
+	d.commands <- debugQuit
+}
+
+// pause is called before every statement and operator call and blocks if the program should be paused there.
+func (d *Debugger[C]) pause(event TraceEvent) {
+	d.pos = event.Pos
+	reason, shouldStop := d.shouldStop(event)
+	if !shouldStop {
+		return
+	}
+
+	variables := make(map[string]interface{}, len(d.program.variables))
+	for name, value := range d.program.variables {
+		variables[name] = value
+	}
+
+	d.stops <- Stop{Reason: reason, Event: event, Variables: variables}
+	d.mode = <-d.commands
+	d.stepDepth = event.Depth
+
+	if d.mode == debugQuit {
+		panic(abort{err: ErrAborted})
+	}
+}
+
+func (d *Debugger[C]) shouldStop(event TraceEvent) (StopReason, bool) {
+	switch {
+	case d.atEntry:
+		d.atEntry = false
+		return StopEntry, true
+	case d.mode == debugStepInto:
+		return StopStep, true
+	case d.mode == debugStepOver && event.Depth <= d.stepDepth:
+		return StopStep, true
+	case d.mode == debugStepOut && event.Depth < d.stepDepth:
+		return StopStep, true
+	}
+
+	if event.Kind != TraceStatement {
+		return 0, false
+	}
+
+	d.mutex.Lock()
+	breakpoints := d.breakpoints[event.Pos.SourceLine()]
+	d.mutex.Unlock()
+
+	for _, breakpoint := range breakpoints {
+		if breakpoint.Condition == nil || breakpoint.Condition(d.program.variables) {
+			return StopBreakpoint, true
+		}
+	}
+	return 0, false
+}
+
+// debugTracer passes the events of a run to the debugger.
+type debugTracer[C any] struct {
+	debugger *Debugger[C]
+}
+
+func (t debugTracer[C]) Enter(event TraceEvent) {
+	t.debugger.pause(event)
+}
+
+func (t debugTracer[C]) Exit(TraceEvent) {}
@@ -0,0 +1,187 @@
+package pala
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// debugSession starts a debugger on the program and performs the given action at every stop. It returns a description
+// of each stop.
+func debugSession(t *testing.T, program string, setup func(d *Debugger[*context]), action func(d *Debugger[*context])) ([]string, error) {
+	prog := parseTraceProgram(t, program)
+	d := NewDebugger(prog)
+	setup(d)
+	d.Start(&context{})
+
+	var stops []string
+	for {
+		stop, paused := d.Wait()
+		if !paused {
+			return stops, d.Err()
+		}
+		stops = append(stops, fmt.Sprintf("%d %d:%d %s %v", stop.Reason, stop.Event.Pos.Line, stop.Event.Pos.Col, stop.Event.Symbol, stop.Variables["$a"]))
+		action(d)
+	}
+}
+
+// alternate returns an action that cycles through the given actions.
+func alternate(actions ...func(d *Debugger[*context])) func(d *Debugger[*context]) {
+	i := 0
+	return func(d *Debugger[*context]) {
+		actions[i%len(actions)](d)
+		i++
+	}
+}
+
+func TestDebugger(t *testing.T) {
+	program := "$a + 1 2\n$a * $a (\n  3\n)\n$a + $a 1"
+
+	tests := []struct {
+		name          string
+		setup         func(d *Debugger[*context])
+		action        func(d *Debugger[*context])
+		expectedStops []string
+	}{
+		{
+			"run without stops",
+			func(d *Debugger[*context]) {},
+			(*Debugger[*context]).Continue,
+			nil,
+		},
+		{
+			"stop on entry",
+			func(d *Debugger[*context]) { d.SetStopOnEntry(true) },
+			(*Debugger[*context]).Continue,
+			[]string{"0 0:0  <nil>"},
+		},
+		{
+			"step into",
+			func(d *Debugger[*context]) { d.SetStopOnEntry(true) },
+			(*Debugger[*context]).StepInto,
+			[]string{"0 0:0  <nil>", "1 0:3 + <nil>", "1 1:0  3", "1 1:3 * 3", "1 4:0  9", "1 4:3 + 9"},
+		},
+		{
+			"step over",
+			func(d *Debugger[*context]) { d.SetStopOnEntry(true) },
+			(*Debugger[*context]).StepOver,
+			[]string{"0 0:0  <nil>", "1 1:0  3", "1 4:0  9"},
+		},
+		{
+			"step out",
+			func(d *Debugger[*context]) { d.SetBreakpoint(Breakpoint{Line: 1}) },
+			alternate((*Debugger[*context]).StepInto, (*Debugger[*context]).StepOut),
+			[]string{"2 1:0  3", "1 1:3 * 3", "1 4:0  9", "1 4:3 + 9"},
+		},
+		{
+			"line breakpoints",
+			func(d *Debugger[*context]) {
+				d.SetBreakpoint(Breakpoint{Line: 1})
+				d.SetBreakpoint(Breakpoint{Line: 4})
+			},
+			(*Debugger[*context]).Continue,
+			[]string{"2 1:0  3", "2 4:0  9"},
+		},
+		{
+			"cleared breakpoint",
+			func(d *Debugger[*context]) {
+				d.SetBreakpoint(Breakpoint{Line: 1})
+				d.ClearBreakpoints(SourceLine{Line: 1})
+			},
+			(*Debugger[*context]).Continue,
+			nil,
+		},
+		{
+			"conditional breakpoint",
+			func(d *Debugger[*context]) {
+				isNine := func(variables map[string]interface{}) bool { return variables["$a"] == 9 }
+				d.SetBreakpoint(Breakpoint{Line: 1, Condition: isNine})
+				d.SetBreakpoint(Breakpoint{Line: 4, Condition: isNine})
+			},
+			(*Debugger[*context]).Continue,
+			[]string{"2 4:0  9"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stops, err := debugSession(t, program, tt.setup, tt.action)
+			if err != nil {
+				t.Fatalf("expected program to finish without error: %s", err)
+			}
+			if strings.Join(stops, "\n") != strings.Join(tt.expectedStops, "\n") {
+				t.Errorf("expected stops\n%s\nbut got\n%s", strings.Join(tt.expectedStops, "\n"), strings.Join(stops, "\n"))
+			}
+		})
+	}
+}
+
+func TestDebugger_Quit(t *testing.T) {
+	setup := func(d *Debugger[*context]) { d.SetStopOnEntry(true) }
+	stops, err := debugSession(t, "$a + 1 2\n+ $a 1", setup, (*Debugger[*context]).Quit)
+
+	if !errors.Is(err, ErrAborted) {
+		t.Errorf("expected run to be aborted but got %v", err)
+	}
+	if len(stops) != 1 {
+		t.Errorf("expected a single stop but got %v", stops)
+	}
+}
+
+func TestDebugger_OperatorPanic(t *testing.T) {
+	lang := NewLanguage[*context]()
+	lang.BindOperator("/", func(a, b int) int { return a / b })
+	lang.BindLiteralEvaluator(ParseInt)
+
+	prog, err := NewParser(NewLexer(strings.NewReader("$a / 4 2\n$b / $a 0")), lang).Parse()
+	if err != nil {
+		t.Fatalf("expected program to be parsed:\n%s", err)
+	}
+
+	d := NewDebugger(prog)
+	d.Start(&context{})
+	if stop, paused := d.Wait(); paused {
+		t.Fatalf("expected program to finish but it stopped at %+v", stop)
+	}
+
+	var runtimeErr *RuntimeError
+	if !errors.As(d.Err(), &runtimeErr) {
+		t.Fatalf("expected a runtime error but got %v", d.Err())
+	}
+	expected := "[line 1] runtime error: integer divide by zero"
+	if runtimeErr.Pos != (Position{Line: 1, Col: 3}) || runtimeErr.Error() != expected {
+		t.Errorf("expected error '%s' at the division but got '%s' at %+v", expected, runtimeErr, runtimeErr.Pos)
+	}
+}
+
+func TestDebugger_BreakpointSources(t *testing.T) {
+	lang := NewLanguage[*context]()
+	lang.BindOperator("+", plus)
+	lang.BindLiteralEvaluator(ParseInt)
+	fsys := fstest.MapFS{"lib.pala": {Data: []byte("$b + 2 2")}}
+
+	lexer := NewLexer(strings.NewReader("$a + 1 1\nimport lib.pala"), WithSource("main.pala"))
+	prog, err := NewParser(lexer, lang, WithImportFS(fsys)).Parse()
+	if err != nil {
+		t.Fatalf("expected program to be parsed:\n%s", err)
+	}
+
+	d := NewDebugger(prog)
+	d.SetBreakpoint(Breakpoint{Source: "lib.pala", Line: 0})
+	d.Start(&context{})
+
+	var stops []Position
+	for {
+		stop, paused := d.Wait()
+		if !paused {
+			break
+		}
+		stops = append(stops, stop.Event.Pos)
+		d.Continue()
+	}
+	if len(stops) != 1 || stops[0] != (Position{Source: "lib.pala"}) {
+		t.Errorf("expected a single stop at the imported statement but got %v", stops)
+	}
+}
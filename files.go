@@ -0,0 +1,86 @@
+package pala
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+)
+
+// ParseFS parses every file with the .pala extension in the file system into its own program, keyed by the path of
+// the file. The path is also the source of the positions in the program. Imports are resolved through the same file
+// system.
+//
+// The programs of all files that could be parsed are returned, together with the errors of the other files joined into
+// a single error.
+func ParseFS[C any](fsys fs.FS, language *Language[C], options ...ParserOption) (map[string]Program[C], error) {
+	var names []string
+	err := fs.WalkDir(fsys, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() && path.Ext(name) == ".pala" {
+			names = append(names, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	programs := make(map[string]Program[C], len(names))
+	var errs []error
+	for _, name := range names {
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		parser := NewParser(NewLexer(bytes.NewReader(data), WithSource(name)), language, append([]ParserOption{WithImportFS(fsys)}, options...)...)
+		parser.file = name
+		program, err := parser.Parse()
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		programs[name] = program
+	}
+	return programs, errors.Join(errs...)
+}
+
+// ParseFiles parses the files at the given paths into a program per file, keyed by path. The path is also the source
+// of the positions in the program. Imports are resolved relative to the directory of each file.
+//
+// Like ParseFS, it returns the programs of all files that could be parsed together with the joined errors of the others.
+func ParseFiles[C any](language *Language[C], paths []string, options ...ParserOption) (map[string]Program[C], error) {
+	sorted := append([]string{}, paths...)
+	sort.Strings(sorted)
+
+	programs := make(map[string]Program[C], len(paths))
+	var errs []error
+	for _, name := range sorted {
+		program, err := parseFile(language, name, options)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		programs[name] = program
+	}
+	return programs, errors.Join(errs...)
+}
+
+func parseFile[C any](language *Language[C], name string, options []ParserOption) (Program[C], error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return Program[C]{}, err
+	}
+	defer file.Close()
+
+	options = append([]ParserOption{WithImportFS(os.DirFS(filepath.Dir(name)))}, options...)
+	return NewParser(NewLexer(bufio.NewReader(file), WithSource(name)), language, options...).Parse()
+}
@@ -0,0 +1,96 @@
+package pala
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func testFilesLanguage() *Language[*context] {
+	lang := NewLanguage[*context]()
+	lang.BindOperator("+", plus)
+	lang.BindLiteralEvaluator(ParseInt)
+	return lang
+}
+
+func TestParseFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.pala":            {Data: []byte("$a + 1 2")},
+		"scripts/b.pala":    {Data: []byte("import ../lib/c.pala\n+ $c 1")},
+		"scripts/bad.pala":  {Data: []byte("\n  + 1")},
+		"lib/c.pala":        {Data: []byte("$c + 2 2")},
+		"lib/broken.pala":   {Data: []byte("import c.pala\nimport missing.pala")},
+		"notes/readme.text": {Data: []byte("not a script")},
+	}
+
+	programs, err := ParseFS(fsys, testFilesLanguage())
+
+	var names []string
+	for name := range programs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	expected := []string{"a.pala", "lib/c.pala", "scripts/b.pala"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected programs %v but got %v", expected, names)
+	}
+
+	if value, _, _ := programs["scripts/b.pala"].Eval(&context{}); value != 5 {
+		t.Errorf("expected imported variable to be used, got %v", value)
+	}
+
+	expectedErrs := []string{
+		"lib/broken.pala:2:1: cannot import lib/missing.pala: open lib/missing.pala: file does not exist",
+		"scripts/bad.pala:2:3: operator + expected 2 operands but got 1",
+	}
+	if err == nil || err.Error() != strings.Join(expectedErrs, "\n") {
+		t.Errorf("expected errors\n%s\nbut got\n%v", strings.Join(expectedErrs, "\n"), err)
+	}
+
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || parseErr.Pos.Source != "lib/broken.pala" {
+		t.Errorf("expected joined errors to contain parse errors with source, got %v", parseErr)
+	}
+}
+
+func TestParseFiles(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	lib := write("lib.pala", "$a + 1 1")
+	main := write("main.pala", "import lib.pala\n+ $a 3")
+	bad := write("bad.pala", "+ 1")
+
+	programs, err := ParseFiles(testFilesLanguage(), []string{main, bad, lib})
+	if len(programs) != 2 {
+		t.Errorf("expected 2 programs, got %d", len(programs))
+	}
+	if value, _, _ := programs[main].Eval(&context{}); value != 5 {
+		t.Errorf("expected 5, got %v", value)
+	}
+	if err == nil || err.Error() != bad+":1:1: operator + expected 2 operands but got 1" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestParseError_Source(t *testing.T) {
+	_, err := NewParser(NewLexer(strings.NewReader("$a + 1 2\n$b + $a x"), WithSource("script.pala")), testFilesLanguage()).Parse()
+	if err == nil || err.Error() != `script.pala:2:9: unknown literal x (pala.ParseInt: strconv.ParseInt: parsing "x": invalid syntax)` {
+		t.Errorf("expected error with file, line and column, got %v", err)
+	}
+
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || parseErr.Pos != (Position{Line: 1, Col: 8, Source: "script.pala"}) {
+		t.Errorf("expected zero based position with source, got %+v", parseErr)
+	}
+}
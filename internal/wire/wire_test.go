@@ -0,0 +1,43 @@
+package wire
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestReadWrite(t *testing.T) {
+	out := &strings.Builder{}
+	for _, body := range []string{`{"a":1}`, `{"b":"ü"}`} {
+		if err := Write(out, []byte(body)); err != nil {
+			t.Fatalf("expected message to be written: %s", err)
+		}
+	}
+
+	if out.String() != "Content-Length: 7\r\n\r\n{\"a\":1}Content-Length: 10\r\n\r\n{\"b\":\"ü\"}" {
+		t.Errorf("unexpected messages %q", out.String())
+	}
+
+	reader := bufio.NewReader(strings.NewReader(out.String()))
+	for _, expected := range []string{`{"a":1}`, `{"b":"ü"}`} {
+		body, err := Read(reader)
+		if err != nil {
+			t.Fatalf("expected message to be read: %s", err)
+		}
+		if string(body) != expected {
+			t.Errorf("expected body %s but got %s", expected, body)
+		}
+	}
+
+	if _, err := Read(reader); err != io.EOF {
+		t.Errorf("expected EOF but got %v", err)
+	}
+}
+
+func TestRead_InvalidHeader(t *testing.T) {
+	_, err := Read(bufio.NewReader(strings.NewReader("Content-Type: text\r\n\r\n{}")))
+	if err == nil {
+		t.Errorf("expected error for missing Content-Length")
+	}
+}
@@ -0,0 +1,160 @@
+package pala
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// structOperand returns the struct type of an operator that takes a single struct operand, such as
+// `func(c C, opts Options) R`, or nil. Scripts can fill the fields of the struct with keyword operands such as
+// `width=80`.
+func (o OperatorInfo) structOperand() reflect.Type {
+	if o.OperandNames != nil || len(o.OperandTypes) != 1 {
+		return nil
+	}
+	operandType := o.OperandTypes[0]
+	if operandType.Kind() != reflect.Struct || isOption(operandType) {
+		return nil
+	}
+	return operandType
+}
+
+// acceptsNames reports whether the operator accepts operands by name.
+func (o OperatorInfo) acceptsNames() bool {
+	return o.OperandNames != nil || o.structOperand() != nil
+}
+
+// operandType returns the type of the positional operand at the given index, or nil if there is none.
+func (o OperatorInfo) operandType(i int) reflect.Type {
+	if i >= len(o.OperandTypes) {
+		return nil
+	}
+	return o.OperandTypes[i]
+}
+
+// spreadType returns a struct type with the types of the positional operands from the given index onwards as fields,
+// which are the types that the elements of a tuple spread over these operands are expected to have.
+func (o OperatorInfo) spreadType(i int) reflect.Type {
+	if i >= len(o.OperandTypes) {
+		return nil
+	}
+	var fields []reflect.StructField
+	for j, operandType := range o.OperandTypes[i:] {
+		fields = append(fields, reflect.StructField{Name: fmt.Sprintf("T%d", j), Type: operandType})
+	}
+	return reflect.StructOf(fields)
+}
+
+// namedOperandType returns the type of the operand or struct field with the given name, or nil if there is none.
+func (o OperatorInfo) namedOperandType(name string) reflect.Type {
+	if i := o.operandIndex(name); i >= 0 {
+		return o.OperandTypes[i]
+	}
+	if structType := o.structOperand(); structType != nil {
+		if field, found := keywordField(structType, name); found {
+			return field.Type
+		}
+	}
+	return nil
+}
+
+// keywordField returns the exported field of a struct that is filled by the keyword operand with the given name. A
+// field tagged with `pala:"name"` is only filled by that name and a field tagged with `pala:"-"` is never filled.
+// Other fields are found like fields of variables, by their exact or capitalized name.
+func keywordField(structType reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		tag, _, _ := strings.Cut(field.Tag.Get("pala"), ",")
+		if tag == name && tag != "-" && field.IsExported() {
+			return field, true
+		}
+	}
+
+	field, found := lookupField(structType, name)
+	if !found || !field.IsExported() || field.Tag.Get("pala") != "" || !settableEmbedding(structType, field.Index) {
+		return reflect.StructField{}, false
+	}
+	return field, true
+}
+
+// settableEmbedding reports whether the embedded pointers on the way to the field at the given index can be allocated,
+// which requires them to be exported.
+func settableEmbedding(structType reflect.Type, index []int) bool {
+	for _, i := range index[:len(index)-1] {
+		field := structType.Field(i)
+		if field.Type.Kind() == reflect.Pointer {
+			if !field.IsExported() {
+				return false
+			}
+			structType = field.Type.Elem()
+		} else {
+			structType = field.Type
+		}
+	}
+	return true
+}
+
+// keywordStruct constructs the struct operand of an operator from keyword operands. Fields without an operand keep
+// their zero value.
+func (l *Language[C]) keywordStruct(structType reflect.Type, symbol string, operands []astNode[C], names []string) (astNode[C], error) {
+	var fields [][]int
+	var values []astNode[C]
+	given := make(map[string]bool)
+	for i, operand := range operands {
+		if i >= len(names) || names[i] == "" {
+			return astNode[C]{}, fmt.Errorf("operator %s expects only named operands or a single %s", symbol, structType)
+		}
+
+		field, found := keywordField(structType, names[i])
+		if !found {
+			return astNode[C]{}, fmt.Errorf("operator %s has no operand %s", symbol, names[i])
+		}
+		if given[field.Name] {
+			return astNode[C]{}, fmt.Errorf("operand %s of operator %s is given more than once", names[i], symbol)
+		}
+		given[field.Name] = true
+
+		value, ok := l.assignable(operand, field.Type)
+		if !ok {
+			return astNode[C]{}, fmt.Errorf("operand %s of operator %s expects %s but got %s", names[i], symbol, field.Type, describeType(operand))
+		}
+		fields = append(fields, field.Index)
+		values = append(values, value)
+	}
+
+	node := keywordStructNode[C](structType, fields, values)
+	node.children = values
+	return node, nil
+}
+
+// keywordStructNode creates an astNode that evaluates to a struct of the given type, with the fields at the given
+// indices set to the values.
+func keywordStructNode[C any](returnType reflect.Type, fields [][]int, values []astNode[C]) astNode[C] {
+	return astNode[C]{
+		returnType: returnType,
+		evaluate: func(rt *runtime[C]) interface{} {
+			result := reflect.New(returnType).Elem()
+			for i, value := range values {
+				if v := reflect.ValueOf(value.evaluate(rt)); v.IsValid() {
+					allocateField(result, fields[i]).Set(v)
+				}
+			}
+			return result.Interface()
+		},
+	}
+}
+
+// allocateField returns the field of a struct at the given index, allocating the nil embedded pointers on the way.
+func allocateField(value reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && value.Kind() == reflect.Pointer {
+			if value.IsNil() {
+				value.Set(reflect.New(value.Type().Elem()))
+			}
+			value = value.Elem()
+		}
+		value = value.Field(x)
+	}
+	return value
+}
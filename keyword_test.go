@@ -0,0 +1,72 @@
+package pala
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type serveOptions struct {
+	Host    string
+	Port    int           `pala:"port"`
+	Timeout time.Duration `pala:"timeout"`
+	Verbose bool          `pala:"-"`
+	Label   Option[string]
+	secret  string
+}
+
+type DeepOptions struct {
+	Depth int
+}
+
+type hiddenOptions struct {
+	Hidden int
+}
+
+type nestedOptions struct {
+	Host string
+	*DeepOptions
+	*hiddenOptions
+}
+
+type server struct {
+	address string
+	timeout time.Duration
+	label   string
+}
+
+func TestLanguage_KeywordOperands(t *testing.T) {
+	lang := NewLanguage[*context]()
+	lang.BindOperator("serve", func(c *context, opts serveOptions) server {
+		return server{address: opts.Host + ":" + strconv.Itoa(opts.Port), timeout: opts.Timeout, label: opts.Label.OrElse("-")}
+	})
+	lang.BindOperator("nested", func(opts nestedOptions) string {
+		return fmt.Sprintf("%s %v %v", opts.Host, opts.DeepOptions, opts.hiddenOptions)
+	})
+	lang.BindOperator("options", func() serveOptions { return serveOptions{Host: "example.com", Port: 80} })
+	lang.BindLiteralEvaluator(ParseString)
+	lang.BindLiteralEvaluator(ParseInt)
+	lang.BindLiteralEvaluator(func(s string) (time.Duration, error) { return time.ParseDuration(s) })
+
+	tests := []evalCase{
+		{"keyword operands", "serve host=localhost port=8080", server{"localhost:8080", 0, "-"}, ""},
+		{"evaluated by field type", "serve timeout=5s port=1 host=10", server{"10:1", 5 * time.Second, "-"}, ""},
+		{"field name", "serve Host=a", server{"a:0", 0, "-"}, ""},
+		{"optional field", "serve label=main", server{":0", 0, "main"}, ""},
+		{"variable value", "$o options\nserve port=$o.port", server{":80", 0, "-"}, ""},
+		{"struct operand", "$o options\nserve $o", server{"example.com:80", 0, "-"}, ""},
+		{"unknown keyword", "serve depth=3", nil, "[line 0] operator serve has no operand depth"},
+		{"tagged field by name", "serve Port=3", nil, "[line 0] operator serve has no operand Port"},
+		{"ignored field", "serve Verbose=true", nil, "[line 0] operator serve has no operand Verbose"},
+		{"unexported field", "serve secret=x", nil, "[line 0] operator serve has no operand secret"},
+		{"duplicate keyword", "serve host=a Host=b", nil, "[line 0] operand Host of operator serve is given more than once"},
+		{"positional and keywords", "$o options\nserve $o port=1", nil, "[line 1] operator serve expects only named operands or a single pala.serveOptions"},
+		{"field of embedded pointer", "nested host=a depth=3", "a &{3} <nil>", ""},
+		{"embedded pointer left nil", "nested host=a", "a <nil> <nil>", ""},
+		{"field of unexported embedded pointer", "nested hidden=1", nil, "[line 0] operator nested has no operand hidden"},
+		{"wrong type", "serve port=x", nil, `[line 0] operand port of operator serve expects int but got string (pala.ParseInt: strconv.ParseInt: parsing "x": invalid syntax)`},
+	}
+
+	runCases(t, lang, tests)
+}
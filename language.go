@@ -3,29 +3,272 @@ package pala
 import (
 	"fmt"
 	"reflect"
+	goruntime "runtime"
+	"sort"
+	"strings"
 )
 
 // Language contains evaluators that convert string symbols to the appropriate literals and functions.
 // You construct the language by defining available literals and operations using the BindLiteralEvaluator and
 // BindOperator methods.
 type Language[C any] struct {
-	operators map[string]func(operands []astNode[C]) (astNode[C], error)
-	literals  []func(token token) (astNode[C], error)
+	operators  map[string]operator[C]
+	literals   []literal[C]
+	converters map[conversion]converter
+	parent     *Language[C] // nil if the language does not extend another language.
+}
+
+type operator[C any] struct {
+	info  OperatorInfo
+	build func(language *Language[C], operatorToken token, operands []astNode[C], names []string) (astNode[C], error)
+}
+
+type literal[C any] struct {
+	info     LiteralInfo
+	evaluate func(token token) (astNode[C], error)
+}
+
+// OperatorInfo describes an operator that is bound to a Language.
+type OperatorInfo struct {
+	Symbol         string
+	OperandTypes   []reflect.Type
+	ReturnType     reflect.Type // nil if the operator does not return a value.
+	AcceptsContext bool
+	Doc            string
+	Capabilities   []string               // Capabilities a parser must allow for scripts to use the operator.
+	OperandNames   []string               // Names of the operands, nil if they can only be given by position.
+	Defaults       map[string]interface{} // Values of named operands that may be omitted.
+}
+
+// Signature returns a human-readable signature of the operator, such as `+ int int -> int`. Named operands are shown
+// with their name and default value, such as `rect width:int height:int=1`.
+func (o OperatorInfo) Signature() string {
+	parts := []string{o.Symbol}
+	for i, operandType := range o.OperandTypes {
+		if o.OperandNames == nil {
+			parts = append(parts, operandType.String())
+			continue
+		}
+		part := o.OperandNames[i] + ":" + operandType.String()
+		if value, has := o.Defaults[o.OperandNames[i]]; has {
+			part += fmt.Sprintf("=%v", value)
+		}
+		parts = append(parts, part)
+	}
+	if o.ReturnType != nil {
+		parts = append(parts, "->", o.ReturnType.String())
+	}
+	return strings.Join(parts, " ")
+}
+
+// LiteralInfo describes a literal evaluator that is bound to a Language.
+type LiteralInfo struct {
+	Name       string // The name of the evaluator, which defaults to the name of its function.
+	ReturnType reflect.Type
+	Priority   int // Evaluators with a higher priority are tried first.
+}
+
+// LiteralOption configures a literal evaluator when it is bound to a Language.
+type LiteralOption func(info *LiteralInfo)
+
+// WithName names a literal evaluator, as it is shown in errors and references.
+func WithName(name string) LiteralOption {
+	return func(info *LiteralInfo) {
+		info.Name = name
+	}
+}
+
+// WithPriority sets the priority of a literal evaluator. Evaluators with a higher priority are tried first, those with
+// the same priority in the order in which they were bound. The default priority is 0.
+func WithPriority(priority int) LiteralOption {
+	return func(info *LiteralInfo) {
+		info.Priority = priority
+	}
+}
+
+// LiteralError is returned when none of the literal evaluators of a Language accepts a literal.
+type LiteralError struct {
+	Literal    string
+	Rejections []LiteralRejection // The evaluators that were tried, in order.
+}
+
+// LiteralRejection holds the error with which a literal evaluator rejected a literal.
+type LiteralRejection struct {
+	Evaluator string
+	Err       error
+}
+
+func (e *LiteralError) Error() string {
+	if len(e.Rejections) == 0 {
+		return fmt.Sprintf("unknown literal %s", e.Literal)
+	}
+	return fmt.Sprintf("unknown literal %s (%s)", e.Literal, formatRejections(e.Rejections))
+}
+
+func formatRejections(rejections []LiteralRejection) string {
+	var reasons []string
+	for _, rejection := range rejections {
+		reasons = append(reasons, fmt.Sprintf("%s: %s", rejection.Evaluator, rejection.Err))
+	}
+	return strings.Join(reasons, "; ")
+}
+
+// describeType describes the type of a node that cannot be given where another type is expected. A literal that was
+// evaluated by a fallback evaluator is followed by the reasons with which the evaluators of the expected type rejected it.
+func describeType[C any](node astNode[C]) string {
+	described := fmt.Sprint(node.returnType)
+	if node.kind == nodeNil {
+		described = "nil"
+	}
+	if len(node.rejections) > 0 {
+		described += " (" + formatRejections(node.rejections) + ")"
+	}
+	return described
+}
+
+// OperatorOption configures an operator when it is bound to a Language.
+type OperatorOption func(info *OperatorInfo)
+
+// WithDoc attaches documentation to an operator.
+func WithDoc(doc string) OperatorOption {
+	return func(info *OperatorInfo) {
+		info.Doc = doc
+	}
+}
+
+// WithOperands names the operands of an operator in order, so that scripts can give them as `name=value`.
+func WithOperands(names ...string) OperatorOption {
+	return func(info *OperatorInfo) {
+		info.OperandNames = names
+	}
+}
+
+// WithDefault gives a named operand a default value, which is used when a script omits the operand. The value must be
+// assignable to the type of the operand.
+func WithDefault(name string, value interface{}) OperatorOption {
+	return func(info *OperatorInfo) {
+		if info.Defaults == nil {
+			info.Defaults = make(map[string]interface{})
+		}
+		info.Defaults[name] = value
+	}
+}
+
+// WithCapabilities tags an operator with the capabilities it requires, such as access to files or the network.
+// Parsers that are restricted with WithAllowedCapabilities reject scripts using the operator unless all of its
+// capabilities are allowed.
+func WithCapabilities(capabilities ...string) OperatorOption {
+	return func(info *OperatorInfo) {
+		info.Capabilities = append(info.Capabilities, capabilities...)
+	}
 }
 
 // NewLanguage constructs an empty Language.
 func NewLanguage[C any]() *Language[C] {
 	return &Language[C]{
-		operators: make(map[string]func(operands []astNode[C]) (astNode[C], error)),
-		literals:  []func(token token) (astNode[C], error){},
+		operators:  make(map[string]operator[C]),
+		literals:   []literal[C]{},
+		converters: make(map[conversion]converter),
+	}
+}
+
+// Extend constructs a child language that has all operators and literal evaluators of this language. Operators bound to
+// the child override operators of this language with the same symbol, and literal evaluators bound to the child are
+// tried before those of this language. Binding to the child does not change this language, while later bindings to
+// this language are visible in the child.
+func (l *Language[C]) Extend() *Language[C] {
+	child := NewLanguage[C]()
+	child.parent = l
+	return child
+}
+
+// Import binds all operators, literal evaluators and converters of the other language to this language. Imported
+// operators and converters replace those that are already bound, imported literal evaluators are tried after the ones
+// that are already bound.
+func (l *Language[C]) Import(other *Language[C]) {
+	l.ImportAs("", other)
+}
+
+// ImportAs imports the other language like Import, but binds its operators under the given namespace: operator `min`
+// is bound as `namespace.min`.
+func (l *Language[C]) ImportAs(namespace string, other *Language[C]) {
+	for symbol, op := range other.allOperators() {
+		if namespace != "" {
+			symbol = namespace + "." + symbol
+			op.info.Symbol = symbol
+		}
+		l.operators[symbol] = op
+	}
+	l.literals = append(l.literals, other.allLiterals()...)
+	for key, convert := range other.allConverters() {
+		l.converters[key] = convert
+	}
+}
+
+// allOperators returns the operators of the language, including those of the languages it extends.
+func (l *Language[C]) allOperators() map[string]operator[C] {
+	operators := make(map[string]operator[C])
+	if l.parent != nil {
+		operators = l.parent.allOperators()
+	}
+	for symbol, op := range l.operators {
+		operators[symbol] = op
+	}
+	return operators
+}
+
+// allLiterals returns the literals of the language followed by those of the languages it extends, ordered by priority.
+func (l *Language[C]) allLiterals() []literal[C] {
+	literals := append([]literal[C]{}, l.literals...)
+	if l.parent != nil {
+		literals = append(literals, l.parent.allLiterals()...)
+	}
+	sort.SliceStable(literals, func(i, j int) bool { return literals[i].info.Priority > literals[j].info.Priority })
+	return literals
+}
+
+// lookupOperator returns the operator bound to the given symbol in the language or the languages it extends.
+func (l *Language[C]) lookupOperator(symbol string) (operator[C], bool) {
+	for language := l; language != nil; language = language.parent {
+		if op, has := language.operators[symbol]; has {
+			return op, true
+		}
+	}
+	return operator[C]{}, false
+}
+
+// Operators returns a description of all bound operators, sorted by symbol.
+func (l *Language[C]) Operators() []OperatorInfo {
+	var infos []OperatorInfo
+	for _, op := range l.allOperators() {
+		infos = append(infos, op.info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Symbol < infos[j].Symbol })
+	return infos
+}
+
+// Operator returns the description of the operator bound to the given symbol.
+func (l *Language[C]) Operator(symbol string) (OperatorInfo, bool) {
+	op, has := l.lookupOperator(symbol)
+	return op.info, has
+}
+
+// Literals returns a description of all bound literal evaluators, in the order in which they are tried when the
+// expected type of a literal is unknown.
+func (l *Language[C]) Literals() []LiteralInfo {
+	var infos []LiteralInfo
+	for _, lit := range l.allLiterals() {
+		infos = append(infos, lit.info)
 	}
+	return infos
 }
 
 // BindLiteralEvaluator adds a literal evaluator to the language.
 // It must be provided with a function with signature `func(string) (any, error)`. This function should try to parse the
 // given string into a literal and return it. It may fail with an error, in which case the parser will proceed to the
-// next literal evaluator function that was bound.
-func (l *Language[C]) BindLiteralEvaluator(evaluator interface{}) {
+// next literal evaluator function that was bound. The error is reported if no evaluator accepts the literal.
+// Options such as WithName and WithPriority can be given to further describe the evaluator.
+func (l *Language[C]) BindLiteralEvaluator(evaluator interface{}, options ...LiteralOption) {
 	funcValue := reflect.ValueOf(evaluator)
 
 	if funcValue.Kind() != reflect.Func {
@@ -52,7 +295,15 @@ func (l *Language[C]) BindLiteralEvaluator(evaluator interface{}) {
 		return valueNode[C](returnType, value), nil
 	}
 
-	l.literals = append(l.literals, primitive)
+	info := LiteralInfo{Name: funcName(funcValue), ReturnType: returnType}
+	for _, option := range options {
+		option(&info)
+	}
+
+	l.literals = append(l.literals, literal[C]{
+		info:     info,
+		evaluate: primitive,
+	})
 }
 
 // BindOperator binds an operator constructing function to be triggered when the given symbol is encountered.
@@ -60,7 +311,8 @@ func (l *Language[C]) BindLiteralEvaluator(evaluator interface{}) {
 // values.
 // If the first value is of the context type `C` of the language, the context will be passed to it during
 // interpretation.
-func (l *Language[C]) BindOperator(symbol string, constructor interface{}) {
+// Options such as WithDoc can be given to further describe the operator.
+func (l *Language[C]) BindOperator(symbol string, constructor interface{}, options ...OperatorOption) {
 	funcValue := reflect.ValueOf(constructor)
 
 	if funcValue.Kind() != reflect.Func {
@@ -91,48 +343,428 @@ func (l *Language[C]) BindOperator(symbol string, constructor interface{}) {
 		returnType = funcType.Out(0)
 	}
 
-	operator := func(operands []astNode[C]) (astNode[C], error) {
-		if numExpectedOperands != len(operands) {
-			return astNode[C]{}, fmt.Errorf("operator %s expected %d operands but got %d", symbol, numExpectedOperands, len(operands))
+	info := OperatorInfo{
+		Symbol:         symbol,
+		OperandTypes:   argTypes,
+		ReturnType:     returnType,
+		AcceptsContext: acceptsContext,
+	}
+	for _, option := range options {
+		option(&info)
+	}
+	info.checkOperands()
+
+	build := func(language *Language[C], operatorToken token, operands []astNode[C], names []string) (astNode[C], error) {
+		if structType := info.structOperand(); structType != nil && hasNames(names) {
+			operand, err := language.keywordStruct(structType, operatorToken.value, operands, names)
+			if err != nil {
+				return astNode[C]{}, err
+			}
+			operands, names = []astNode[C]{operand}, nil
+		}
+
+		operands, err := arrangeOperands(info, operatorToken.value, operands, names)
+		if err != nil {
+			return astNode[C]{}, err
 		}
 		for i, operand := range operands {
-			if argTypes[i].Kind() == reflect.Slice && operand.returnType == nil {
+			if argTypes[i].Kind() == reflect.Slice && operand.returnType == nil && len(operand.children) == 0 {
 				// slice types accept nil: this equates to an empty slice of the appropriate type.
-				operands[i] = emptySliceNode[C](argTypes[i])
+				empty := emptySliceNode[C](argTypes[i])
+				empty.start, empty.end = operand.start, operand.end
+				operands[i] = empty
 				continue
 			}
-			if argTypes[i] == operand.returnType {
+			if argTypes[i].Kind() == reflect.Map && operand.returnType == nil && len(operand.children) == 0 {
+				// map types accept nil as well, which equates to an empty map.
+				empty := emptyMapNode[C](argTypes[i])
+				empty.start, empty.end = operand.start, operand.end
+				operands[i] = empty
 				continue
 			}
-			if argTypes[i].Kind() == reflect.Interface && operand.returnType.Implements(argTypes[i]) {
+			if converted, ok := language.assignable(operand, argTypes[i]); ok {
+				if err := checkNil(converted); err != nil {
+					return astNode[C]{}, err
+				}
+				operands[i] = converted
 				continue
 			}
 
-			return astNode[C]{}, fmt.Errorf("operand %d of operator %s expects %s but got %v", i, symbol, argTypes[i], operand.returnType)
+			return astNode[C]{}, fmt.Errorf("operand %s of operator %s expects %s but got %s", info.operandName(i), operatorToken.value, argTypes[i], describeType(operand))
 		}
-		return operatorNode[C](returnType, acceptsContext, funcValue, operands), nil
+		return operatorNode[C](operatorToken, returnType, acceptsContext, funcValue, operands), nil
 	}
 
-	l.operators[symbol] = operator
+	l.operators[symbol] = operator[C]{info: info, build: build}
 }
 
 func (l *Language[C]) parseLiteral(token token) (astNode[C], error) {
-	for _, literal := range l.literals {
-		node, err := literal(token)
+	literalErr := &LiteralError{Literal: token.value}
+	for _, literal := range l.allLiterals() {
+		node, err := literal.evaluate(token)
 		if err != nil {
+			literalErr.Rejections = append(literalErr.Rejections, LiteralRejection{Evaluator: literal.info.Name, Err: err})
 			continue
 		}
 		return node, nil
 	}
-	return astNode[C]{}, fmt.Errorf("unknown literal %s", token.value)
+	return astNode[C]{}, literalErr
+}
+
+// parseLiteralAs evaluates a literal that is given where the expected type is expected. Evaluators that return the
+// expected type are tried first, then evaluators whose values can be converted to it, each in the order in which they
+// are tried by parseLiteral. If none of them accepts the literal, or the expected type is nil, the literal is evaluated
+// like parseLiteral, and the node keeps the rejections of the evaluators that were tried first.
+func (l *Language[C]) parseLiteralAs(token token, expectedType reflect.Type) (astNode[C], error) {
+	var rejections []LiteralRejection
+	if expectedType != nil {
+		literals := l.allLiterals()
+		for _, allowConversion := range []bool{false, true} {
+			for _, literal := range literals {
+				if !l.accepts(expectedType, literal.info.ReturnType, allowConversion) {
+					continue
+				}
+				if allowConversion && l.accepts(expectedType, literal.info.ReturnType, false) {
+					// already tried without conversion.
+					continue
+				}
+				node, err := literal.evaluate(token)
+				if err != nil {
+					rejections = append(rejections, LiteralRejection{Evaluator: literal.info.Name, Err: err})
+					continue
+				}
+				return node, nil
+			}
+		}
+	}
+	node, err := l.parseLiteral(token)
+	if err != nil {
+		return astNode[C]{}, err
+	}
+	node.rejections = rejections
+	return node, nil
+}
+
+// accepts reports whether a value of the given type can be given where the target type is expected, possibly wrapped
+// in an Option or, when allowed, after a conversion.
+func (l *Language[C]) accepts(targetType, valueType reflect.Type, allowConversion bool) bool {
+	switch {
+	case valueType == targetType:
+		return true
+	case targetType.Kind() == reflect.Interface && valueType.Implements(targetType):
+		return true
+	case isOption(targetType):
+		return l.accepts(reflect.Zero(targetType).Interface().(optional).valueType(), valueType, allowConversion)
+	case allowConversion:
+		_, has := l.lookupConverter(valueType, targetType)
+		return has
+	default:
+		return false
+	}
 }
 
-func (l *Language[C]) parseOperation(token token, operands []astNode[C]) (astNode[C], error) {
-	operator, has := l.operators[token.value]
+func (l *Language[C]) parseOperation(token token, operands []astNode[C], names []string) (astNode[C], error) {
+	operator, has := l.lookupOperator(token.value)
 	if !has {
 		return astNode[C]{}, fmt.Errorf("unknown operator %s", token.value)
 	}
-	return operator(operands)
+	node, err := operator.build(l, token, operands, names)
+	if err != nil {
+		return astNode[C]{}, err
+	}
+	node.operator = &operator.info
+	return node, nil
+}
+
+// checkOperands panics if the names or default values of the operands do not match the operands of the operator.
+func (o OperatorInfo) checkOperands() {
+	if o.OperandNames != nil && len(o.OperandNames) != len(o.OperandTypes) {
+		panic(fmt.Sprintf("operator %s has %d operands but %d names", o.Symbol, len(o.OperandTypes), len(o.OperandNames)))
+	}
+	for name, value := range o.Defaults {
+		i := o.operandIndex(name)
+		if i < 0 {
+			panic(fmt.Sprintf("operator %s has no operand %s", o.Symbol, name))
+		}
+		operandType := o.OperandTypes[i]
+		if value == nil && acceptsNil(operandType) {
+			o.Defaults[name] = reflect.Zero(operandType).Interface()
+			continue
+		}
+		if value == nil || !reflect.TypeOf(value).AssignableTo(operandType) {
+			panic(fmt.Sprintf("default of operand %s of operator %s must be of type %s", name, o.Symbol, operandType))
+		}
+	}
+}
+
+// operandIndex returns the index of the operand with the given name, or -1 if there is none.
+func (o OperatorInfo) operandIndex(name string) int {
+	for i, operandName := range o.OperandNames {
+		if operandName == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// operandName returns the name of an operand, or its index if the operands are not named.
+func (o OperatorInfo) operandName(i int) string {
+	if o.OperandNames == nil {
+		return fmt.Sprint(i)
+	}
+	return o.OperandNames[i]
+}
+
+// omittable reports whether the operand may be omitted, because it has a default value or is an Option.
+func (o OperatorInfo) omittable(i int) bool {
+	if o.OperandNames != nil {
+		if _, has := o.Defaults[o.OperandNames[i]]; has {
+			return true
+		}
+	}
+	return isOption(o.OperandTypes[i])
+}
+
+// arrangeOperands orders the positional and named operands of an operation by the operands of the operator. Omitted
+// operands get their default value, or an absent Option.
+func arrangeOperands[C any](info OperatorInfo, symbol string, operands []astNode[C], names []string) ([]astNode[C], error) {
+	var positional []astNode[C]
+	for i, operand := range operands {
+		if i >= len(names) || names[i] == "" {
+			positional = append(positional, operand)
+		}
+	}
+	positional = spreadTuples(positional, info.OperandTypes)
+
+	numExpected := len(info.OperandTypes)
+	numRequired := numExpected
+	for numRequired > 0 && info.omittable(numRequired-1) {
+		numRequired--
+	}
+	if len(positional) > numExpected || (len(positional) == len(operands) && len(positional) < numRequired) {
+		if numRequired == numExpected {
+			return nil, fmt.Errorf("operator %s expected %d operands but got %d", symbol, numExpected, len(positional))
+		}
+		return nil, fmt.Errorf("operator %s expected %d to %d operands but got %d", symbol, numRequired, numExpected, len(positional))
+	}
+
+	arranged := make([]astNode[C], numExpected)
+	given := make([]bool, numExpected)
+	for i, operand := range positional {
+		arranged[i], given[i] = operand, true
+	}
+	for i, name := range names {
+		if name == "" {
+			continue
+		}
+		index := info.operandIndex(name)
+		if index < 0 {
+			return nil, fmt.Errorf("operator %s has no operand %s", symbol, name)
+		}
+		if given[index] {
+			return nil, fmt.Errorf("operand %s of operator %s is given more than once", name, symbol)
+		}
+		arranged[index], given[index] = operands[i], true
+	}
+
+	for i := range arranged {
+		if given[i] {
+			continue
+		}
+		if value, has := info.Defaults[info.operandName(i)]; has {
+			arranged[i] = valueNode[C](info.OperandTypes[i], value)
+		} else if isOption(info.OperandTypes[i]) {
+			arranged[i] = zeroNode[C](info.OperandTypes[i])
+		} else {
+			return nil, fmt.Errorf("operator %s is missing operand %s", symbol, info.operandName(i))
+		}
+	}
+	return arranged, nil
+}
+
+// funcName returns the name of a function without its package path, such as `pala.ParseInt`.
+func funcName(funcValue reflect.Value) string {
+	function := goruntime.FuncForPC(funcValue.Pointer())
+	if function == nil {
+		return funcValue.Type().String()
+	}
+	name := function.Name()
+	return name[strings.LastIndex(name, "/")+1:]
+}
+
+func hasNames(names []string) bool {
+	for _, name := range names {
+		if name != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// spreadTuples replaces tuple operands by their elements, unless the tuple is passed to a struct parameter.
+func spreadTuples[C any](operands []astNode[C], argTypes []reflect.Type) []astNode[C] {
+	var result []astNode[C]
+	for _, operand := range operands {
+		i := len(result)
+		if operand.kind == nodeTuple && (i >= len(argTypes) || argTypes[i].Kind() != reflect.Struct) {
+			result = append(result, operand.children...)
+			continue
+		}
+		result = append(result, operand)
+	}
+	return result
+}
+
+// convertComposite converts a tuple to a struct of the given type, or a list or map to a slice or map of the given type
+// by converting its elements. It returns false if the node cannot be converted.
+func (l *Language[C]) convertComposite(node astNode[C], targetType reflect.Type) (astNode[C], bool) {
+	var converted astNode[C]
+	elements := make([]astNode[C], len(node.children))
+	switch {
+	case node.kind == nodeTuple && targetType.Kind() == reflect.Struct:
+		if targetType.NumField() != len(node.children) {
+			return astNode[C]{}, false
+		}
+		for i, element := range node.children {
+			field := targetType.Field(i)
+			if !field.IsExported() {
+				return astNode[C]{}, false
+			}
+			var ok bool
+			if elements[i], ok = l.assignable(element, field.Type); !ok {
+				return astNode[C]{}, false
+			}
+		}
+		converted = structNode[C](targetType, elements)
+
+	case node.kind == nodeList && targetType.Kind() == reflect.Slice:
+		for i, element := range node.children {
+			var ok bool
+			if elements[i], ok = l.assignable(element, targetType.Elem()); !ok {
+				return astNode[C]{}, false
+			}
+		}
+		converted = sliceNode[C](targetType, node.start, elements)
+
+	case node.kind == nodeMap && targetType.Kind() == reflect.Map:
+		for i, element := range node.children {
+			elementType := targetType.Elem()
+			if i%2 == 0 {
+				elementType = targetType.Key()
+			}
+			var ok bool
+			if elements[i], ok = l.assignable(element, elementType); !ok {
+				return astNode[C]{}, false
+			}
+		}
+		converted = mapNode[C](targetType, node.start, elements)
+
+	default:
+		return astNode[C]{}, false
+	}
+
+	converted.start, converted.end, converted.kind = node.start, node.end, node.kind
+	converted.children = elements
+	return converted, true
+}
+
+// untypedCompositeType returns the type given to a list or map whose elements do not determine its type, such as `[]`
+// or `{a: nil}`, when it is passed as an interface: a slice of interface{} or a map of interface{} values.
+func untypedCompositeType[C any](node astNode[C]) (reflect.Type, bool) {
+	switch node.kind {
+	case nodeList:
+		return reflect.SliceOf(anyType), true
+	case nodeMap:
+		keyType := stringType
+		if len(node.children) > 0 && node.children[0].returnType != nil {
+			keyType = node.children[0].returnType
+		}
+		return reflect.MapOf(keyType, anyType), true
+	default:
+		return nil, false
+	}
+}
+
+// assignable returns the node, converted if needed, if its value can be assigned to the given type. Composite literals
+// are converted before converters of the language are tried, and values are wrapped last when an Option is expected.
+func (l *Language[C]) assignable(node astNode[C], targetType reflect.Type) (astNode[C], bool) {
+	switch {
+	case node.kind == nodeNil:
+		if !acceptsNil(targetType) {
+			return astNode[C]{}, false
+		}
+		zero := zeroNode[C](targetType)
+		zero.start, zero.end, zero.kind = node.start, node.end, node.kind
+		return zero, true
+	case node.returnType == targetType:
+		return node, true
+	case node.returnType != nil && targetType.Kind() == reflect.Interface && node.returnType.Implements(targetType):
+		return node, true
+	case node.returnType == nil && targetType.Kind() == reflect.Interface:
+		defaultType, ok := untypedCompositeType(node)
+		if !ok || !defaultType.Implements(targetType) {
+			return astNode[C]{}, false
+		}
+		return l.convertComposite(node, defaultType)
+	}
+	if converted, ok := l.convertComposite(node, targetType); ok {
+		return converted, true
+	}
+	if converted, ok := l.coerce(node, targetType); ok {
+		return converted, true
+	}
+	if isOption(targetType) {
+		valueType := reflect.Zero(targetType).Interface().(optional).valueType()
+		if value, ok := l.assignable(node, valueType); ok {
+			wrapped := optionNode(value, targetType)
+			wrapped.start, wrapped.end, wrapped.children = node.start, node.end, node.children
+			return wrapped, true
+		}
+	}
+	return astNode[C]{}, false
+}
+
+// interfaceTypes returns the interface types that the operators of the language accept, directly or as the elements
+// of slices and maps, sorted by name.
+func (l *Language[C]) interfaceTypes() []reflect.Type {
+	seen := make(map[reflect.Type]bool)
+	var result []reflect.Type
+	for _, op := range l.allOperators() {
+		for _, operandType := range op.info.OperandTypes {
+			for operandType.Kind() == reflect.Slice || operandType.Kind() == reflect.Map {
+				operandType = operandType.Elem()
+			}
+			if operandType.Kind() == reflect.Interface && !seen[operandType] {
+				seen[operandType] = true
+				result = append(result, operandType)
+			}
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].String() < result[j].String() })
+	return result
+}
+
+// commonInterface returns the narrowest interface type, the one with the most methods, that is accepted by an operator
+// of the language and implemented by all given types. It falls back to the empty interface.
+func (l *Language[C]) commonInterface(types []reflect.Type) reflect.Type {
+	var best reflect.Type
+	for _, candidate := range l.interfaceTypes() {
+		implemented := true
+		for _, t := range types {
+			if t != nil && !t.Implements(candidate) {
+				implemented = false
+				break
+			}
+		}
+		if implemented && (best == nil || candidate.NumMethod() > best.NumMethod()) {
+			best = candidate
+		}
+	}
+	if best == nil {
+		return anyType
+	}
+	return best
 }
 
 var stringType = reflect.TypeOf("")
+var anyType = reflect.TypeOf((*interface{})(nil)).Elem()
@@ -0,0 +1,334 @@
+package pala
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func evalWith(t *testing.T, lang *Language[*context], program string) interface{} {
+	prog, err := NewParser(NewLexer(strings.NewReader(program)), lang).Parse()
+	if err != nil {
+		t.Fatalf("expected program to be parsed:\n%s", err)
+	}
+	value, _, err := prog.Eval(&context{})
+	if err != nil {
+		t.Fatalf("expected program to run:\n%s", err)
+	}
+	return value
+}
+
+// evalCase is a program with the value of its last statement, or with the error it fails to parse or run with.
+type evalCase struct {
+	name           string
+	program        string
+	expectedValue  interface{}
+	expectedErrMsg string
+}
+
+// runCases parses and runs the program of every case in a subtest and compares the outcome with the expectation.
+func runCases(t *testing.T, lang *Language[*context], cases []evalCase) {
+	t.Helper()
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			prog, err := NewParser(NewLexer(strings.NewReader(tt.program)), lang).Parse()
+			if err == nil {
+				var value interface{}
+				value, _, err = prog.Eval(&context{})
+				if err == nil && !reflect.DeepEqual(value, tt.expectedValue) {
+					t.Errorf("expected %v (%T) but got %v (%T)", tt.expectedValue, tt.expectedValue, value, value)
+				}
+			}
+
+			if tt.expectedErrMsg == "" {
+				if err != nil {
+					t.Errorf("expected program to run, got %s", err)
+				}
+			} else if err == nil || err.Error() != tt.expectedErrMsg {
+				t.Errorf("expected error '%s' but got '%v'", tt.expectedErrMsg, err)
+			}
+		})
+	}
+}
+
+func symbols(infos []OperatorInfo) []string {
+	var result []string
+	for _, info := range infos {
+		result = append(result, info.Symbol)
+	}
+	return result
+}
+
+func TestLanguage_Import(t *testing.T) {
+	math := NewLanguage[*context]()
+	math.BindOperator("+", plus)
+	math.BindOperator("min", smallest)
+	math.BindLiteralEvaluator(ParseInt)
+
+	lang := NewLanguage[*context]()
+	lang.BindOperator("+", mul)
+	lang.Import(math)
+
+	if value := evalWith(t, lang, "+ 2 3"); value != 5 {
+		t.Errorf("expected imported operator to replace the existing one, got %v", value)
+	}
+	if value := evalWith(t, lang, "min [4 2 3]"); value != 2 {
+		t.Errorf("expected 2, got %v", value)
+	}
+	if len(lang.Literals()) != 1 {
+		t.Errorf("expected literal evaluators to be imported")
+	}
+}
+
+func TestLanguage_ImportAs(t *testing.T) {
+	math := NewLanguage[*context]()
+	math.BindOperator("+", plus)
+	math.BindOperator("min", smallest)
+
+	lang := NewLanguage[*context]()
+	lang.BindOperator("+", mul)
+	lang.BindLiteralEvaluator(ParseInt)
+	lang.ImportAs("math", math)
+
+	expected := []string{"+", "math.+", "math.min"}
+	if actual := symbols(lang.Operators()); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected operators %v but got %v", expected, actual)
+	}
+	if value := evalWith(t, lang, "math.+ 2 3\n+ 2 3"); value != 6 {
+		t.Errorf("expected the operator without namespace to be kept, got %v", value)
+	}
+	if value := evalWith(t, lang, "math.min [4 2 3]"); value != 2 {
+		t.Errorf("expected 2, got %v", value)
+	}
+
+	_, err := NewParser(NewLexer(strings.NewReader("math.+ 1")), lang).Parse()
+	if err == nil || err.Error() != "[line 0] operator math.+ expected 2 operands but got 1" {
+		t.Errorf("expected error to use the namespaced symbol, got %v", err)
+	}
+	if info, _ := math.Operator("min"); info.Symbol != "min" {
+		t.Errorf("expected imported language not to be changed, got %s", info.Symbol)
+	}
+}
+
+func TestLanguage_Extend(t *testing.T) {
+	base := NewLanguage[*context]()
+	base.BindOperator("+", plus)
+	base.BindOperator("-", neg)
+	base.BindLiteralEvaluator(ParseString)
+
+	child := base.Extend()
+	child.BindOperator("+", mul)
+	child.BindLiteralEvaluator(ParseInt)
+
+	if value := evalWith(t, child, "+ 2 3"); value != 6 {
+		t.Errorf("expected child operator to override the base operator, got %v", value)
+	}
+	if value := evalWith(t, child, "- 2"); value != -2 {
+		t.Errorf("expected base operator to be available in the child, got %v", value)
+	}
+	if _, err := NewParser(NewLexer(strings.NewReader("+ 2 3")), base).Parse(); err == nil {
+		t.Errorf("expected base language to be unchanged and not evaluate integer literals")
+	}
+
+	base.BindOperator("*", mul)
+	expected := []string{"*", "+", "-"}
+	if actual := symbols(child.Operators()); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected operators %v but got %v", expected, actual)
+	}
+
+	expectedLiterals := []LiteralInfo{{Name: "pala.ParseInt", ReturnType: reflect.TypeOf(0)}, {Name: "pala.ParseString", ReturnType: reflect.TypeOf("")}}
+	if actual := child.Literals(); !reflect.DeepEqual(actual, expectedLiterals) {
+		t.Errorf("expected child literals to be tried first, got %v", actual)
+	}
+}
+
+type frame struct {
+	width, height int
+	fill          string
+}
+
+func TestLanguage_NamedOperands(t *testing.T) {
+	lang := NewLanguage[*context]()
+	lang.BindOperator("rect", func(width, height int, fill string) frame { return frame{width, height, fill} },
+		WithOperands("width", "height", "fill"), WithDefault("height", 1), WithDefault("fill", "none"))
+	lang.BindOperator("sum", func(xs []int, start int) int {
+		for _, x := range xs {
+			start += x
+		}
+		return start
+	}, WithOperands("xs", "start"), WithDefault("start", 0))
+	lang.BindOperator("pair", func(a, b string) string { return a + b })
+	lang.BindLiteralEvaluator(ParseInt)
+	lang.BindLiteralEvaluator(ParseString)
+
+	tests := []evalCase{
+		{"positional", "rect 2 3 red", frame{2, 3, "red"}, ""},
+		{"defaults", "rect 2", frame{2, 1, "none"}, ""},
+		{"named", "rect width=2 fill=blue", frame{2, 1, "blue"}, ""},
+		{"named in any order", "rect height=4 width=2", frame{2, 4, "none"}, ""},
+		{"positional and named", "rect 2 fill=red", frame{2, 1, "red"}, ""},
+		{"value in next token", "rect width= 2", frame{2, 1, "none"}, ""},
+		{"named variable", "$w sum [1 2]\nrect width=$w", frame{3, 1, "none"}, ""},
+		{"named list", "sum start=1 xs=[1 2]", 4, ""},
+		{"multi-line", "rect (\n  width=2\n  height=3\n)", frame{2, 3, "none"}, ""},
+		{"unnamed operator keeps literal", "pair a=b c", "a=bc", ""},
+		{"unknown name", "rect 2 depth=3", nil, "[line 0] operator rect has no operand depth"},
+		{"duplicate name", "rect width=2 width=3", nil, "[line 0] operand width of operator rect is given more than once"},
+		{"named and positional", "rect 2 width=3", nil, "[line 0] operand width of operator rect is given more than once"},
+		{"positional after named", "rect width=2 3", nil, "[line 0] positional operand after named operand"},
+		{"missing operand", "rect height=2", nil, "[line 0] operator rect is missing operand width"},
+		{"too few operands", "rect", nil, "[line 0] operator rect expected 1 to 3 operands but got 0"},
+		{"missing value", "rect width=", nil, "[line 0] missing value for operand width"},
+		{"wrong type", "rect width=x", nil, `[line 0] operand width of operator rect expects int but got string (pala.ParseInt: strconv.ParseInt: parsing "x": invalid syntax)`},
+	}
+
+	runCases(t, lang, tests)
+
+	info, _ := lang.Operator("rect")
+	if signature := info.Signature(); signature != "rect width:int height:int=1 fill:string=none -> pala.frame" {
+		t.Errorf("unexpected signature %s", signature)
+	}
+}
+
+func TestLanguage_NamedOperandsMisuse(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  []OperatorOption
+		expected string
+	}{
+		{"too few names", []OperatorOption{WithOperands("a")}, "operator f has 2 operands but 1 names"},
+		{"unknown default", []OperatorOption{WithOperands("a", "b"), WithDefault("c", 1)}, "operator f has no operand c"},
+		{"default of wrong type", []OperatorOption{WithOperands("a", "b"), WithDefault("b", "x")}, "default of operand b of operator f must be of type int"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recovered := recover(); recovered != tt.expected {
+					t.Errorf("expected panic '%s' but got '%v'", tt.expected, recovered)
+				}
+			}()
+			NewLanguage[*context]().BindOperator("f", func(a, b int) int { return a + b }, tt.options...)
+		})
+	}
+}
+
+func TestLanguage_TypedLiterals(t *testing.T) {
+	lang := NewLanguage[*context]()
+	lang.BindLiteralEvaluator(ParseString)
+	lang.BindLiteralEvaluator(parseFloat)
+	lang.BindLiteralEvaluator(ParseInt)
+	lang.BindOperator("+", plus)
+	lang.BindOperator("half", func(f float64) float64 { return f / 2 })
+	lang.BindOperator("concat", func(a string, b string) string { return a + b })
+	lang.BindOperator("sum", func(xs []int) int {
+		total := 0
+		for _, x := range xs {
+			total += x
+		}
+		return total
+	})
+	lang.BindOperator("lookup", func(m map[int]string, key int) string { return m[key] })
+	lang.BindOperator("area", func(p point) int { return p.X * p.Y })
+	lang.BindOperator("scale", func(p point, factor Option[int]) int { return p.X * factor.OrElse(1) })
+	lang.BindOperator("add", func(a, b, c int) int { return a + b + c })
+	lang.BindOperator("show", func(v interface{}) string { return fmt.Sprintf("%T", v) })
+
+	tests := []struct {
+		name     string
+		program  string
+		expected interface{}
+	}{
+		{"operand type", "+ 1 2", 3},
+		{"exact type before conversion", "half 3", 1.5},
+		{"string operands", "concat 1 2", "12"},
+		{"list elements", "sum [1 2 3]", 6},
+		{"map keys and values", "lookup {1: a 2: b} 2", "b"},
+		{"tuple fields", "area @(2 3)", 6},
+		{"spread tuple", "add @(1 2) 3", 6},
+		{"option", "scale @(2 3) 4", 8},
+		{"unknown type falls back to bind order", "show 1", "string"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if value := evalWith(t, lang, tt.program); value != tt.expected {
+				t.Errorf("expected %v but got %v", tt.expected, value)
+			}
+		})
+	}
+}
+
+func TestLanguage_LiteralPriority(t *testing.T) {
+	lang := NewLanguage[*context]()
+	lang.BindLiteralEvaluator(ParseString)
+	lang.BindLiteralEvaluator(ParseInt, WithPriority(1))
+	lang.BindLiteralEvaluator(ParseRational, WithName("rational"), WithPriority(1))
+	lang.BindOperator("show", func(v interface{}) string { return fmt.Sprintf("%T", v) })
+
+	expected := []LiteralInfo{
+		{Name: "pala.ParseInt", ReturnType: reflect.TypeOf(0), Priority: 1},
+		{Name: "rational", ReturnType: reflect.TypeOf(&big.Rat{}), Priority: 1},
+		{Name: "pala.ParseString", ReturnType: stringType},
+	}
+	if actual := lang.Literals(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected literals %v but got %v", expected, actual)
+	}
+
+	for program, expectedType := range map[string]string{"show 1": "int", "show 1/2": "*big.Rat", "show a": "string"} {
+		if value := evalWith(t, lang, program); value != expectedType {
+			t.Errorf("expected %s to be evaluated as %s but got %v", program, expectedType, value)
+		}
+	}
+
+	child := lang.Extend()
+	child.BindLiteralEvaluator(ParseQuotedString, WithPriority(2))
+	if literals := child.Literals(); literals[0].Name != "pala.ParseQuotedString" || literals[3].Name != "pala.ParseString" {
+		t.Errorf("expected literals of the child to be ordered by priority, got %v", literals)
+	}
+}
+
+func TestLanguage_LiteralError(t *testing.T) {
+	lang := NewLanguage[*context]()
+	lang.BindLiteralEvaluator(ParseInt)
+	lang.BindLiteralEvaluator(ParseRational, WithName("rational"))
+	lang.BindOperator("show", func(v interface{}) string { return fmt.Sprint(v) })
+
+	_, err := NewParser(NewLexer(strings.NewReader("show x")), lang).Parse()
+	expectedMsg := `[line 0] unknown literal x (pala.ParseInt: strconv.ParseInt: parsing "x": invalid syntax; rational: no valid rational)`
+	if err == nil || err.Error() != expectedMsg {
+		t.Errorf("expected error '%s' but got '%v'", expectedMsg, err)
+	}
+
+	var literalErr *LiteralError
+	if !errors.As(err, &literalErr) {
+		t.Fatalf("expected a literal error but got %v", err)
+	}
+	if literalErr.Literal != "x" || len(literalErr.Rejections) != 2 || literalErr.Rejections[1].Evaluator != "rational" {
+		t.Errorf("unexpected literal error %+v", literalErr)
+	}
+
+	empty := NewLanguage[*context]()
+	empty.BindOperator("show", func(v interface{}) string { return fmt.Sprint(v) })
+	_, err = NewParser(NewLexer(strings.NewReader("show x")), empty).Parse()
+	if err == nil || err.Error() != "[line 0] unknown literal x" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestLanguage_LiteralTypeMismatch(t *testing.T) {
+	lang := NewLanguage[*context]()
+	lang.BindOperator("+", plus)
+	lang.BindLiteralEvaluator(ParseInt)
+	lang.BindLiteralEvaluator(ParseString)
+
+	tests := []evalCase{
+		{"rejections of the expected type", "+ x 1", nil, `[line 0] operand 0 of operator + expects int but got string (pala.ParseInt: strconv.ParseInt: parsing "x": invalid syntax)`},
+		{"accepted by the expected type", "+ 2 1", 3, ""},
+	}
+
+	runCases(t, lang, tests)
+}
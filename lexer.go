@@ -1,8 +1,11 @@
 package pala
 
 import (
+	"fmt"
 	"io"
+	"strings"
 	"unicode"
+	"unicode/utf8"
 )
 
 type Lexer interface {
@@ -17,6 +20,9 @@ const (
 	tokenVariable
 	tokenLBracket
 	tokenRBracket
+	tokenLBrace
+	tokenRBrace
+	tokenTupleStart
 	tokenLParen
 	tokenRParen
 	tokenComment
@@ -26,39 +32,109 @@ const (
 
 type token struct {
 	tpe   tokenType
-	line  int
+	pos   Position
 	value string
 }
 
+// end returns the position directly after the token.
+func (t token) end() Position {
+	end := t.pos
+	end.Col += utf8.RuneCountInString(t.value)
+	return end
+}
+
+// Position is a location in the source. Both line and column are zero based.
+// Source is the name of the source that was given to the lexer with WithSource, if any.
+type Position struct {
+	Line   int
+	Col    int
+	Source string
+}
+
+// prefix formats the position for the start of an error message: `file:line:col:` with one based line and column if
+// the source is known, `[line N]` otherwise.
+func (p Position) prefix() string {
+	if p.Source == "" {
+		return fmt.Sprintf("[line %d]", p.Line)
+	}
+	return fmt.Sprintf("%s:%d:%d:", p.Source, p.Line+1, p.Col+1)
+}
+
+// SourceLine returns the line of the position.
+func (p Position) SourceLine() SourceLine {
+	return SourceLine{Source: p.Source, Line: p.Line}
+}
+
+// SourceLine identifies a line of a source. Line is zero based, like the line of a Position.
+type SourceLine struct {
+	Source string // Empty for a lexer without WithSource.
+	Line   int
+}
+
+// String returns the line, prefixed with the source if it is known, such as `main.pala:3`. Like the prefix of errors,
+// the line is one based only when the source is known.
+func (l SourceLine) String() string {
+	if l.Source == "" {
+		return fmt.Sprintf("%d", l.Line)
+	}
+	return fmt.Sprintf("%s:%d", l.Source, l.Line+1)
+}
+
+// Before reports whether the position lies before the other position.
+func (p Position) Before(other Position) bool {
+	return p.Line < other.Line || (p.Line == other.Line && p.Col < other.Col)
+}
+
 type basicLexer struct {
 	scanner  io.RuneScanner
 	next     func(l *basicLexer) token
 	currCh   rune
-	currLine int
+	currPos  Position
+	tokenPos Position
+	tuples   int // Number of open tuples, within which a closing parenthesis also ends a word.
+}
+
+// LexerOption configures a lexer.
+type LexerOption func(lexer *basicLexer)
+
+// WithSource names the source that is read by the lexer, such as a file path. The name is part of the positions of the
+// tokens and is used in error messages.
+func WithSource(name string) LexerOption {
+	return func(lexer *basicLexer) {
+		lexer.currPos.Source = name
+	}
 }
 
-func NewLexer(scanner io.RuneScanner) Lexer {
-	lexer := &basicLexer{scanner: scanner, next: readLine}
+func NewLexer(scanner io.RuneScanner, options ...LexerOption) Lexer {
+	lexer := &basicLexer{scanner: scanner, next: readLine, currPos: Position{Col: -1}}
+	for _, option := range options {
+		option(lexer)
+	}
 	lexer.readChar()
 	return lexer
 }
 
 func (l *basicLexer) nextToken() token {
 	l.skipWhitespace()
+	l.tokenPos = l.currPos
 
 	return l.next(l)
 }
 
 func (l *basicLexer) readChar() {
+	if l.currCh == '\n' {
+		l.currPos.Line++
+		l.currPos.Col = 0
+	} else if l.currCh != 0 || l.currPos.Col < 0 {
+		l.currPos.Col++
+	}
+
 	ch, _, err := l.scanner.ReadRune()
 	if err != nil {
 		l.currCh = 0
 	} else {
 		l.currCh = ch
 	}
-	if l.currCh == '\n' {
-		l.currLine++
-	}
 }
 
 func (l *basicLexer) skipWhitespace() {
@@ -78,13 +154,64 @@ func (l *basicLexer) scanLine() string {
 
 func (l *basicLexer) scanWord() string {
 	var result []rune
-	for unicode.IsGraphic(l.currCh) && !unicode.IsSpace(l.currCh) && l.currCh != '[' && l.currCh != ']' && !isLineEnd(l.currCh) {
+	for unicode.IsGraphic(l.currCh) && !unicode.IsSpace(l.currCh) && !isDelimiter(l.currCh) && !(l.tuples > 0 && l.currCh == ')') && !isLineEnd(l.currCh) {
+		if l.currCh == '"' && len(result) > 0 && result[len(result)-1] == '=' {
+			break // The quoted value of a named operand is scanned by scanQuoted.
+		}
 		result = append(result, l.currCh)
 		l.readChar()
 	}
 	return string(result)
 }
 
+// scanVariable scans a variable together with its accessors, so that `$xs[$i].name` is a single token. The text between
+// brackets is scanned as is, up to the matching closing bracket on the same line.
+func (l *basicLexer) scanVariable() string {
+	return l.scanAccessors(l.scanWord())
+}
+
+// scanAccessors continues a scanned variable with the accessors that follow it.
+func (l *basicLexer) scanAccessors(variable string) string {
+	result := []rune(variable)
+	for l.currCh == '[' {
+		depth := 0
+		for !isLineEnd(l.currCh) {
+			if l.currCh == '[' {
+				depth++
+			} else if l.currCh == ']' {
+				depth--
+			}
+			result = append(result, l.currCh)
+			l.readChar()
+			if depth == 0 {
+				break
+			}
+		}
+		result = append(result, []rune(l.scanWord())...)
+	}
+	return string(result)
+}
+
+// scanQuoted scans a string in double quotes as a single word, so that it may contain whitespace and delimiters. A
+// backslash escapes the next character. An unterminated string ends at the end of the line.
+func (l *basicLexer) scanQuoted() string {
+	result := []rune{l.currCh}
+	l.readChar()
+	for !isLineEnd(l.currCh) {
+		ch := l.currCh
+		result = append(result, ch)
+		l.readChar()
+		if ch == '"' {
+			break
+		}
+		if ch == '\\' && !isLineEnd(l.currCh) {
+			result = append(result, l.currCh)
+			l.readChar()
+		}
+	}
+	return string(result)
+}
+
 func readLine(l *basicLexer) token {
 	switch {
 	case l.currCh == '(':
@@ -92,6 +219,9 @@ func readLine(l *basicLexer) token {
 		return l.makeToken(tokenLParen, "(")
 	case l.currCh == ')':
 		l.readChar()
+		if l.tuples > 0 {
+			l.tuples--
+		}
 		return l.makeToken(tokenRParen, ")")
 	case l.currCh == '[':
 		l.readChar()
@@ -99,15 +229,39 @@ func readLine(l *basicLexer) token {
 	case l.currCh == ']':
 		l.readChar()
 		return l.makeToken(tokenRBracket, "]")
+	case l.currCh == '{':
+		l.readChar()
+		return l.makeToken(tokenLBrace, "{")
+	case l.currCh == '}':
+		l.readChar()
+		return l.makeToken(tokenRBrace, "}")
+	case l.currCh == '@':
+		l.readChar()
+		if l.currCh == '(' {
+			l.readChar()
+			l.tuples++
+			return l.makeToken(tokenTupleStart, "@(")
+		}
+		return l.makeToken(tokenLiteral, "@"+l.scanWord())
 	case l.currCh == '#':
 		return l.makeToken(tokenComment, l.scanLine())
 	case l.currCh == '\n':
 		l.readChar()
 		return l.makeToken(tokenNewline, "\n")
 	case l.currCh == '$':
-		return l.makeToken(tokenVariable, l.scanWord())
+		return l.makeToken(tokenVariable, l.scanVariable())
+	case l.currCh == '"':
+		return l.makeToken(tokenLiteral, l.scanQuoted())
 	case unicode.IsGraphic(l.currCh):
-		return l.makeToken(tokenLiteral, l.scanWord())
+		word := l.scanWord()
+		if strings.Contains(word, "=$") {
+			// A named operand such as `n=$xs[0]` has a variable as value.
+			word = l.scanAccessors(word)
+		} else if strings.HasSuffix(word, "=") && l.currCh == '"' {
+			// A named operand such as `title="a b"` has a quoted string as value.
+			word += l.scanQuoted()
+		}
+		return l.makeToken(tokenLiteral, word)
 	case l.currCh == 0:
 		return l.makeToken(tokenEOF, "")
 	default:
@@ -118,7 +272,40 @@ func readLine(l *basicLexer) token {
 }
 
 func (l *basicLexer) makeToken(tpe tokenType, Value string) token {
-	return token{tpe: tpe, value: Value, line: l.currLine}
+	return token{tpe: tpe, value: Value, pos: l.tokenPos}
+}
+
+// IsComplete reports whether every opening parenthesis, brace and tuple in the source is closed again.
+// A REPL can use this to decide whether to read another line before parsing the input.
+func IsComplete(source string) bool {
+	lexer := NewLexer(strings.NewReader(source))
+	open := false
+	braces, tuples := 0, 0
+	for {
+		switch lexer.nextToken().tpe {
+		case tokenLParen:
+			open = true
+		case tokenTupleStart:
+			tuples++
+		case tokenRParen:
+			if tuples > 0 {
+				tuples--
+			} else {
+				open = false
+			}
+		case tokenLBrace:
+			braces++
+		case tokenRBrace:
+			braces--
+		case tokenEOF:
+			return !open && braces <= 0 && tuples <= 0
+		}
+	}
+}
+
+// isDelimiter reports whether the character ends a word, even if it is not preceded by whitespace.
+func isDelimiter(c rune) bool {
+	return c == '[' || c == ']' || c == '{' || c == '}'
 }
 
 func isLineEnd(c rune) bool {
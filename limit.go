@@ -0,0 +1,115 @@
+package pala
+
+import (
+	"fmt"
+	"time"
+)
+
+// Limit identifies a resource budget of a run.
+type Limit int
+
+const (
+	LimitSteps Limit = iota
+	LimitSliceElements
+	LimitTime
+	LimitDepth
+)
+
+func (l Limit) String() string {
+	switch l {
+	case LimitSteps:
+		return "step"
+	case LimitSliceElements:
+		return "slice element"
+	case LimitTime:
+		return "time"
+	case LimitDepth:
+		return "depth"
+	default:
+		return fmt.Sprintf("Limit(%d)", int(l))
+	}
+}
+
+// LimitExceededError is returned when a run exceeds one of its budgets. Pos is the position of the expression that was
+// being evaluated when the budget was exhausted.
+type LimitExceededError struct {
+	Limit Limit
+	Pos   Position
+}
+
+func (e *LimitExceededError) Error() string {
+	return fmt.Sprintf("%s %s limit exceeded", e.Pos.prefix(), e.Limit)
+}
+
+// WithMaxSteps limits the number of operator calls of the run.
+func WithMaxSteps(steps int) RunOption {
+	return func(config *runConfig) {
+		config.maxSteps = steps
+	}
+}
+
+// WithMaxSliceElements limits the total number of elements of all lists and entries of all maps that are built during
+// the run, including the elements of slices that are converted to another slice type.
+func WithMaxSliceElements(elements int) RunOption {
+	return func(config *runConfig) {
+		config.maxSliceElements = elements
+	}
+}
+
+// WithMaxDepth limits how deeply lists, maps and operator calls are nested while they are evaluated, such as the lists
+// in `[[[1]]]` which are nested three deep. It guards the host against scripts that nest deep enough to exhaust the
+// stack.
+func WithMaxDepth(depth int) RunOption {
+	return func(config *runConfig) {
+		config.maxDepth = depth
+	}
+}
+
+// WithTimeout limits the wall time of the run. The time is checked before every statement, operator call and list
+// element, so an operator that does not return is not interrupted.
+func WithTimeout(timeout time.Duration) RunOption {
+	return func(config *runConfig) {
+		config.timeout = timeout
+	}
+}
+
+// step counts an operator call at the given position.
+func (rt *runtime[C]) step(pos Position) {
+	rt.steps++
+	if rt.config.maxSteps > 0 && rt.steps > rt.config.maxSteps {
+		rt.exceed(LimitSteps, pos)
+	}
+	rt.checkDeadline(pos)
+}
+
+// allocate counts the list elements that are built at the given position.
+func (rt *runtime[C]) allocate(pos Position, elements int) {
+	rt.sliceElements += elements
+	if rt.config.maxSliceElements > 0 && rt.sliceElements > rt.config.maxSliceElements {
+		rt.exceed(LimitSliceElements, pos)
+	}
+	rt.checkDeadline(pos)
+}
+
+// enter counts the nesting of a list, map or operator call that is evaluated at the given position. It must be
+// followed by leave once the evaluation has finished.
+func (rt *runtime[C]) enter(pos Position) {
+	rt.nesting++
+	if rt.config.maxDepth > 0 && rt.nesting > rt.config.maxDepth {
+		rt.exceed(LimitDepth, pos)
+	}
+}
+
+func (rt *runtime[C]) leave() {
+	rt.nesting--
+}
+
+func (rt *runtime[C]) checkDeadline(pos Position) {
+	if !rt.deadline.IsZero() && time.Now().After(rt.deadline) {
+		rt.exceed(LimitTime, pos)
+	}
+}
+
+func (rt *runtime[C]) exceed(limit Limit, pos Position) {
+	panic(abort{err: &LimitExceededError{Limit: limit, Pos: pos}})
+}
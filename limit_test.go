@@ -0,0 +1,108 @@
+package pala
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProgram_Limits(t *testing.T) {
+	tests := map[string]struct {
+		program  string
+		option   RunOption
+		expected *LimitExceededError
+	}{
+		"steps within budget": {
+			program: "+ 1 2\n+ 3 4",
+			option:  WithMaxSteps(2),
+		},
+		"steps exceeded": {
+			program:  "+ 1 2\n* 3 4\n+ 5 6",
+			option:   WithMaxSteps(2),
+			expected: &LimitExceededError{Limit: LimitSteps, Pos: Position{Line: 2, Col: 0}},
+		},
+		"slice elements within budget": {
+			program: "$a [1 2]\n$b [3 4]",
+			option:  WithMaxSliceElements(4),
+		},
+		"slice elements exceeded": {
+			program:  "$a [1 2]\n$b [[3] [4 5]]",
+			option:   WithMaxSliceElements(4),
+			expected: &LimitExceededError{Limit: LimitSliceElements, Pos: Position{Line: 1, Col: 3}},
+		},
+		"depth within budget": {
+			program: "$a [[1] [2]]\n+ 1 2",
+			option:  WithMaxDepth(2),
+		},
+		"depth exceeded": {
+			program:  "$a [[[1]] [[2]]]",
+			option:   WithMaxDepth(2),
+			expected: &LimitExceededError{Limit: LimitDepth, Pos: Position{Line: 0, Col: 5}},
+		},
+		"time exceeded": {
+			program:  "+ 1 2",
+			option:   WithTimeout(time.Nanosecond),
+			expected: &LimitExceededError{Limit: LimitTime, Pos: Position{Line: 0, Col: 0}},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			prog := parseTraceProgram(t, test.program)
+			err := prog.Run(&context{}, test.option)
+
+			if test.expected == nil {
+				if err != nil {
+					t.Errorf("expected run to succeed, got %s", err)
+				}
+				return
+			}
+
+			var limitErr *LimitExceededError
+			if !errors.As(err, &limitErr) {
+				t.Fatalf("expected a LimitExceededError, got %v", err)
+			}
+			if *limitErr != *test.expected {
+				t.Errorf("expected %+v but got %+v", test.expected, limitErr)
+			}
+		})
+	}
+}
+
+func TestProgram_ConvertedSliceLimit(t *testing.T) {
+	lang := NewLanguage[*context]()
+	lang.BindOperator("ints", func() []int { return []int{1, 2, 3} })
+	lang.BindOperator("count", func(xs []float64) int { return len(xs) })
+	lang.BindLiteralEvaluator(ParseInt)
+
+	prog, err := NewParser(NewLexer(strings.NewReader("$xs ints\ncount $xs")), lang).Parse()
+	if err != nil {
+		t.Fatalf("expected program to be parsed:\n%s", err)
+	}
+
+	if err := prog.Run(&context{}, WithMaxSliceElements(3)); err != nil {
+		t.Errorf("expected run to succeed, got %s", err)
+	}
+	var limitErr *LimitExceededError
+	if err := prog.Run(&context{}, WithMaxSliceElements(2)); !errors.As(err, &limitErr) || limitErr.Pos != (Position{Line: 1, Col: 6}) {
+		t.Errorf("expected converted elements to exceed the budget at the variable, got %v", err)
+	}
+}
+
+func TestProgram_LimitsPerRun(t *testing.T) {
+	prog := parseTraceProgram(t, "+ 1 2\n+ 3 4")
+
+	for i := 0; i < 3; i++ {
+		if err := prog.Run(&context{}, WithMaxSteps(2)); err != nil {
+			t.Fatalf("expected every run to have its own budget, got %s", err)
+		}
+	}
+}
+
+func TestLimitExceededError(t *testing.T) {
+	err := &LimitExceededError{Limit: LimitSliceElements, Pos: Position{Line: 3}}
+	if !strings.Contains(err.Error(), "[line 3] slice element limit exceeded") {
+		t.Errorf("unexpected error message %q", err.Error())
+	}
+}
@@ -0,0 +1,438 @@
+// Package lsp implements a Language Server Protocol server for scripts written in a pala Language.
+//
+// The server communicates over a single reader and writer, such as the standard input and output of a process, and
+// provides diagnostics, completion, hover information and go-to-definition for variables.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"unicode/utf16"
+
+	"github.com/RoelofRuis/pala"
+)
+
+// Server is a language server for a Language.
+type Server[C any] struct {
+	language  *pala.Language[C]
+	documents map[string]*document[C]
+
+	outMutex sync.Mutex
+	out      io.Writer
+}
+
+// document is an open text document together with the result of parsing it.
+type document[C any] struct {
+	lines     []string
+	variables []pala.VariableInfo
+	program   *pala.Program[C] // nil if the document could not be parsed.
+}
+
+// NewServer constructs a language server for the given language.
+func NewServer[C any](language *pala.Language[C]) *Server[C] {
+	return &Server[C]{
+		language:  language,
+		documents: make(map[string]*document[C]),
+	}
+}
+
+// Serve handles messages read from in and writes the responses to out, until the client sends the exit notification
+// or in is closed.
+func (s *Server[C]) Serve(in io.Reader, out io.Writer) error {
+	s.out = out
+	reader := bufio.NewReader(in)
+	for {
+		msg, err := readMessage(reader)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if msg.Method == "exit" {
+			return nil
+		}
+
+		result, respErr := s.handle(msg)
+		if msg.ID == nil {
+			// Notifications are never answered.
+			continue
+		}
+
+		response := message{ID: msg.ID}
+		if respErr != nil {
+			response.Error = respErr
+		} else {
+			response.Result, _ = json.Marshal(result)
+		}
+		if writeErr := s.write(response); writeErr != nil {
+			return writeErr
+		}
+	}
+}
+
+func (s *Server[C]) handle(msg message) (interface{}, *responseError) {
+	switch msg.Method {
+	case "initialize":
+		return map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"textDocumentSync":   textDocumentSyncFull,
+				"completionProvider": map[string]interface{}{"triggerCharacters": []string{"$"}},
+				"hoverProvider":      true,
+				"definitionProvider": true,
+			},
+			"serverInfo": map[string]string{"name": "pala"},
+		}, nil
+
+	case "shutdown":
+		return nil, nil
+
+	case "textDocument/didOpen":
+		var params didOpenParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		s.update(params.TextDocument.URI, params.TextDocument.Text)
+		return nil, nil
+
+	case "textDocument/didChange":
+		var params didChangeParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		if len(params.ContentChanges) > 0 {
+			s.update(params.TextDocument.URI, params.ContentChanges[len(params.ContentChanges)-1].Text)
+		}
+		return nil, nil
+
+	case "textDocument/didClose":
+		var params didCloseParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		delete(s.documents, params.TextDocument.URI)
+		s.publishDiagnostics(params.TextDocument.URI, nil)
+		return nil, nil
+
+	case "textDocument/completion":
+		var params textDocumentPositionParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		return s.completion(params), nil
+
+	case "textDocument/hover":
+		var params textDocumentPositionParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		return s.hover(params), nil
+
+	case "textDocument/definition":
+		var params textDocumentPositionParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		return s.definition(params), nil
+
+	case "initialized", "$/cancelRequest", "$/setTrace":
+		return nil, nil
+
+	default:
+		return nil, &responseError{Code: codeMethodNotFound, Message: fmt.Sprintf("method %s is not supported", msg.Method)}
+	}
+}
+
+// update parses the new text of a document and publishes the resulting diagnostics.
+func (s *Server[C]) update(uri, text string) {
+	parser := pala.NewParser(pala.NewLexer(strings.NewReader(text)), s.language, parserOptions(uri)...)
+	program, err := parser.Parse()
+
+	doc := &document[C]{
+		lines:     strings.Split(text, "\n"),
+		variables: parser.Variables(),
+	}
+	if err == nil {
+		doc.program = &program
+	}
+	s.documents[uri] = doc
+
+	var diagnostics []diagnostic
+	if err != nil {
+		diagnostics = append(diagnostics, doc.diagnostic(err))
+	}
+	s.publishDiagnostics(uri, diagnostics)
+}
+
+// parserOptions returns the options to parse the document with the given URI. Imports of documents that are files are
+// resolved relative to the directory of the file.
+func parserOptions(uri string) []pala.ParserOption {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return nil
+	}
+	return []pala.ParserOption{pala.WithImportFS(os.DirFS(filepath.Dir(filepath.FromSlash(u.Path))))}
+}
+
+func (s *Server[C]) publishDiagnostics(uri string, diagnostics []diagnostic) {
+	if diagnostics == nil {
+		diagnostics = []diagnostic{}
+	}
+	params, _ := json.Marshal(publishDiagnosticsParams{URI: uri, Diagnostics: diagnostics})
+	_ = s.write(message{Method: "textDocument/publishDiagnostics", Params: params})
+}
+
+func (s *Server[C]) completion(params textDocumentPositionParams) []completionItem {
+	items := []completionItem{}
+	doc, has := s.documents[params.TextDocument.URI]
+	if !has {
+		return items
+	}
+
+	word, _ := doc.wordAt(params.Position)
+	if !strings.HasPrefix(word, "$") {
+		for _, op := range s.language.Operators() {
+			items = append(items, completionItem{
+				Label:         op.Symbol,
+				Kind:          completionKindFunction,
+				Detail:        op.Signature(),
+				Documentation: op.Doc,
+			})
+		}
+	}
+	for _, variable := range doc.variables {
+		items = append(items, completionItem{
+			Label:  variable.Name,
+			Kind:   completionKindVariable,
+			Detail: typeName(variable.Type),
+		})
+	}
+	return items
+}
+
+func (s *Server[C]) hover(params textDocumentPositionParams) *hover {
+	doc, has := s.documents[params.TextDocument.URI]
+	if !has {
+		return nil
+	}
+
+	word, wordRange := doc.wordAt(params.Position)
+	if wordRange.End.Character <= params.Position.Character {
+		return nil
+	}
+
+	if variable, has := doc.variable(word); has {
+		return &hover{
+			Contents: markupContent{Kind: "markdown", Value: fmt.Sprintf("```\n%s %s\n```", variable.Name, typeName(variable.Type))},
+			Range:    &wordRange,
+		}
+	}
+
+	if doc.program != nil {
+		pos := pala.Position{Line: params.Position.Line, Col: doc.runeOffset(params.Position)}
+		if expr, found := doc.program.ExpressionAt(pos); found {
+			exprRange := textRange{Start: doc.position(expr.Start), End: doc.position(expr.End)}
+			if expr.Operator != nil {
+				return &hover{Contents: operatorContent(*expr.Operator), Range: &exprRange}
+			}
+			return &hover{
+				Contents: markupContent{Kind: "markdown", Value: fmt.Sprintf("```\n%s\n```", typeName(expr.Type))},
+				Range:    &exprRange,
+			}
+		}
+	}
+
+	if op, has := s.language.Operator(word); has {
+		return &hover{Contents: operatorContent(op), Range: &wordRange}
+	}
+
+	return nil
+}
+
+func (s *Server[C]) definition(params textDocumentPositionParams) *location {
+	doc, has := s.documents[params.TextDocument.URI]
+	if !has {
+		return nil
+	}
+
+	word, _ := doc.wordAt(params.Position)
+	variable, has := doc.variable(word)
+	if !has {
+		return nil
+	}
+
+	uri := sourceURI(params.TextDocument.URI, variable.Pos.Source)
+	lines := doc.lines
+	if variable.Pos.Source != "" {
+		lines = readLines(uri)
+	}
+	end := variable.Pos
+	end.Col += len([]rune(variable.Name))
+	return &location{URI: uri, Range: textRange{Start: utf16Position(lines, variable.Pos), End: utf16Position(lines, end)}}
+}
+
+// readLines returns the lines of the file with the given URI, or nil if it cannot be read.
+func readLines(uri string) []string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return nil
+	}
+	data, err := os.ReadFile(filepath.FromSlash(u.Path))
+	if err != nil {
+		return nil
+	}
+	return strings.Split(string(data), "\n")
+}
+
+// sourceURI returns the URI of a position source in a document. Documents are parsed without source, while files that
+// they import have their path relative to the document as source.
+func sourceURI(documentURI, source string) string {
+	if source == "" {
+		return documentURI
+	}
+	u, err := url.Parse(documentURI)
+	if err != nil {
+		return documentURI
+	}
+	u.Path = path.Join(path.Dir(u.Path), source)
+	return u.String()
+}
+
+func (s *Server[C]) write(msg message) error {
+	s.outMutex.Lock()
+	defer s.outMutex.Unlock()
+	return writeMessage(s.out, msg)
+}
+
+func operatorContent(op pala.OperatorInfo) markupContent {
+	value := fmt.Sprintf("```\n%s\n```", op.Signature())
+	if op.Doc != "" {
+		value += "\n\n" + op.Doc
+	}
+	return markupContent{Kind: "markdown", Value: value}
+}
+
+// diagnostic converts a parse error to a diagnostic that spans from the error position to the end of its line.
+func (d *document[C]) diagnostic(err error) diagnostic {
+	var start position
+	var parseErr *pala.ParseError
+	message := err.Error()
+	if errors.As(err, &parseErr) {
+		start = d.position(parseErr.Pos)
+		message = parseErr.Msg
+	}
+
+	end := start
+	if start.Line < len(d.lines) {
+		end.Character = len(utf16.Encode([]rune(d.lines[start.Line])))
+	}
+	if end.Character <= start.Character {
+		end.Character = start.Character + 1
+	}
+
+	return diagnostic{
+		Range:    textRange{Start: start, End: end},
+		Severity: severityError,
+		Source:   "pala",
+		Message:  message,
+	}
+}
+
+// wordAt returns the word that contains or ends at the given position.
+func (d *document[C]) wordAt(pos position) (string, textRange) {
+	if pos.Line < 0 || pos.Line >= len(d.lines) {
+		return "", textRange{}
+	}
+	line := []rune(d.lines[pos.Line])
+	col := d.runeOffset(pos)
+	if col < 0 || col > len(line) {
+		return "", textRange{}
+	}
+
+	start := col
+	for start > 0 && !isSeparator(line[start-1]) {
+		start--
+	}
+	end := col
+	for end < len(line) && !isSeparator(line[end]) {
+		end++
+	}
+
+	return string(line[start:end]), textRange{
+		Start: d.position(pala.Position{Line: pos.Line, Col: start}),
+		End:   d.position(pala.Position{Line: pos.Line, Col: end}),
+	}
+}
+
+// position converts a position in the document, of which the column counts runes, to a protocol position, of which
+// the character counts UTF-16 code units.
+func (d *document[C]) position(pos pala.Position) position {
+	return utf16Position(d.lines, pos)
+}
+
+// runeOffset converts the UTF-16 character offset of a protocol position to a rune offset within its line. Offsets
+// beyond the end of the line are kept beyond it.
+func (d *document[C]) runeOffset(pos position) int {
+	if pos.Line < 0 || pos.Line >= len(d.lines) {
+		return pos.Character
+	}
+	units := 0
+	for i, r := range []rune(d.lines[pos.Line]) {
+		if units >= pos.Character {
+			return i
+		}
+		if r >= 0x10000 {
+			units += 2 // A surrogate pair.
+		} else {
+			units++
+		}
+	}
+	return len([]rune(d.lines[pos.Line])) + pos.Character - units
+}
+
+// utf16Position converts a position, of which the column counts runes, to a protocol position within the given lines.
+func utf16Position(lines []string, pos pala.Position) position {
+	if pos.Line < 0 || pos.Line >= len(lines) {
+		return position{Line: pos.Line, Character: pos.Col}
+	}
+	line := []rune(lines[pos.Line])
+	if pos.Col > len(line) {
+		return position{Line: pos.Line, Character: len(utf16.Encode(line)) + pos.Col - len(line)}
+	}
+	return position{Line: pos.Line, Character: len(utf16.Encode(line[:pos.Col]))}
+}
+
+func (d *document[C]) variable(name string) (pala.VariableInfo, bool) {
+	for _, variable := range d.variables {
+		if variable.Name == name {
+			return variable, true
+		}
+	}
+	return pala.VariableInfo{}, false
+}
+
+func invalidParams(err error) *responseError {
+	return &responseError{Code: codeInvalidParams, Message: err.Error()}
+}
+
+func typeName(t reflect.Type) string {
+	if t == nil {
+		return "none"
+	}
+	return t.String()
+}
+
+func isSeparator(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\r' || r == '[' || r == ']' || r == '{' || r == '}' || r == '(' || r == ')'
+}
@@ -0,0 +1,233 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/RoelofRuis/pala"
+)
+
+type context struct{}
+
+func newTestLanguage() *pala.Language[*context] {
+	lang := pala.NewLanguage[*context]()
+	lang.BindOperator("+", func(a, b int) int { return a + b }, pala.WithDoc("Adds two integers."))
+	lang.BindOperator("echo", func(a any) {})
+	lang.BindLiteralEvaluator(pala.ParseInt)
+	return lang
+}
+
+// session runs the server on the given requests and returns all messages that were written by the server.
+func session(t *testing.T, requests ...string) []message {
+	in := &strings.Builder{}
+	for _, request := range requests {
+		_, _ = fmt.Fprintf(in, "Content-Length: %d\r\n\r\n%s", len(request), request)
+	}
+
+	out := &strings.Builder{}
+	if err := NewServer(newTestLanguage()).Serve(strings.NewReader(in.String()), out); err != nil {
+		t.Fatalf("expected server to end without error: %s", err)
+	}
+
+	var messages []message
+	reader := bufio.NewReader(strings.NewReader(out.String()))
+	for {
+		msg, err := readMessage(reader)
+		if err == io.EOF {
+			return messages
+		}
+		if err != nil {
+			t.Fatalf("expected valid message: %s", err)
+		}
+		messages = append(messages, msg)
+	}
+}
+
+func didOpen(text string) string {
+	params, _ := json.Marshal(map[string]interface{}{
+		"textDocument": map[string]string{"uri": "file:///test.pala", "text": text},
+	})
+	return fmt.Sprintf(`{"jsonrpc":"2.0","method":"textDocument/didOpen","params":%s}`, params)
+}
+
+func positionRequest(id int, method string, line, character int) string {
+	return fmt.Sprintf(
+		`{"jsonrpc":"2.0","id":%d,"method":"%s","params":{"textDocument":{"uri":"file:///test.pala"},"position":{"line":%d,"character":%d}}}`,
+		id, method, line, character,
+	)
+}
+
+func TestServer_Initialize(t *testing.T) {
+	messages := session(t,
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`,
+		`{"jsonrpc":"2.0","id":2,"method":"shutdown"}`,
+		`{"jsonrpc":"2.0","method":"exit"}`,
+	)
+
+	if len(messages) != 2 {
+		t.Fatalf("expected 2 responses but got %d", len(messages))
+	}
+	if !strings.Contains(string(messages[0].Result), `"hoverProvider":true`) {
+		t.Errorf("expected hover capability but got %s", messages[0].Result)
+	}
+	if string(messages[1].Result) != "null" {
+		t.Errorf("expected null shutdown result but got %s", messages[1].Result)
+	}
+}
+
+func TestServer_Diagnostics(t *testing.T) {
+	messages := session(t, didOpen("$a + 1 2\n+ $a $b"))
+
+	if len(messages) != 1 || messages[0].Method != "textDocument/publishDiagnostics" {
+		t.Fatalf("expected diagnostics to be published but got %+v", messages)
+	}
+
+	var params publishDiagnosticsParams
+	if err := json.Unmarshal(messages[0].Params, &params); err != nil {
+		t.Fatalf("expected valid diagnostics: %s", err)
+	}
+	if len(params.Diagnostics) != 1 {
+		t.Fatalf("expected 1 diagnostic but got %d", len(params.Diagnostics))
+	}
+	diag := params.Diagnostics[0]
+	if diag.Message != "encountered undeclared variable $b" || diag.Range.Start != (position{Line: 1, Character: 5}) {
+		t.Errorf("unexpected diagnostic %+v", diag)
+	}
+}
+
+func TestServer_Features(t *testing.T) {
+	tests := []struct {
+		name     string
+		request  string
+		expected string
+	}{
+		{
+			"complete operators and variables",
+			positionRequest(1, "textDocument/completion", 1, 0),
+			`[{"label":"+","kind":3,"detail":"+ int int -\u003e int","documentation":"Adds two integers."},{"label":"echo","kind":3,"detail":"echo interface {}"},{"label":"$a","kind":6,"detail":"int"}]`,
+		},
+		{
+			"complete variables",
+			positionRequest(1, "textDocument/completion", 1, 6),
+			`[{"label":"$a","kind":6,"detail":"int"}]`,
+		},
+		{
+			"hover operator",
+			positionRequest(1, "textDocument/hover", 1, 0),
+			"{\"contents\":{\"kind\":\"markdown\",\"value\":\"```\\n+ int int -\\u003e int\\n```\\n\\nAdds two integers.\"},\"range\":{\"start\":{\"line\":1,\"character\":0},\"end\":{\"line\":1,\"character\":1}}}",
+		},
+		{
+			"hover variable",
+			positionRequest(1, "textDocument/hover", 1, 3),
+			"{\"contents\":{\"kind\":\"markdown\",\"value\":\"```\\n$a int\\n```\"},\"range\":{\"start\":{\"line\":1,\"character\":2},\"end\":{\"line\":1,\"character\":4}}}",
+		},
+		{
+			"hover nothing",
+			positionRequest(1, "textDocument/hover", 1, 1),
+			"null",
+		},
+		{
+			"definition of variable",
+			positionRequest(1, "textDocument/definition", 1, 3),
+			`{"uri":"file:///test.pala","range":{"start":{"line":0,"character":0},"end":{"line":0,"character":2}}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messages := session(t, didOpen("$a + 1 2\n+ $a $"), tt.request)
+			if len(messages) != 2 {
+				t.Fatalf("expected diagnostics and a response but got %+v", messages)
+			}
+			if string(messages[1].Result) != tt.expected {
+				t.Errorf("expected result\n%s\nbut got\n%s", tt.expected, messages[1].Result)
+			}
+		})
+	}
+}
+
+func TestServer_HoverExpression(t *testing.T) {
+	tests := []struct {
+		name     string
+		request  string
+		expected string
+	}{
+		{
+			"hover literal",
+			positionRequest(1, "textDocument/hover", 0, 5),
+			"{\"contents\":{\"kind\":\"markdown\",\"value\":\"```\\nint\\n```\"},\"range\":{\"start\":{\"line\":0,\"character\":5},\"end\":{\"line\":0,\"character\":6}}}",
+		},
+		{
+			"hover operation",
+			positionRequest(1, "textDocument/hover", 0, 3),
+			"{\"contents\":{\"kind\":\"markdown\",\"value\":\"```\\n+ int int -\\u003e int\\n```\\n\\nAdds two integers.\"},\"range\":{\"start\":{\"line\":0,\"character\":3},\"end\":{\"line\":0,\"character\":8}}}",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messages := session(t, didOpen("$a + 1 2"), tt.request)
+			if len(messages) != 2 {
+				t.Fatalf("expected diagnostics and a response but got %+v", messages)
+			}
+			if string(messages[1].Result) != tt.expected {
+				t.Errorf("expected result\n%s\nbut got\n%s", tt.expected, messages[1].Result)
+			}
+		})
+	}
+}
+
+func TestSourceURI(t *testing.T) {
+	tests := []struct {
+		document string
+		source   string
+		expected string
+	}{
+		{"file:///scripts/main.pala", "", "file:///scripts/main.pala"},
+		{"file:///scripts/main.pala", "lib/defs.pala", "file:///scripts/lib/defs.pala"},
+		{"file:///scripts/main.pala", "../shared.pala", "file:///shared.pala"},
+	}
+
+	for _, tt := range tests {
+		if actual := sourceURI(tt.document, tt.source); actual != tt.expected {
+			t.Errorf("expected %s for source %q but got %s", tt.expected, tt.source, actual)
+		}
+	}
+}
+
+func TestServer_UTF16Positions(t *testing.T) {
+	text := "$😀 + 1 2\n+ $😀 $b"
+
+	messages := session(t, didOpen(text))
+	var params publishDiagnosticsParams
+	if err := json.Unmarshal(messages[0].Params, &params); err != nil || len(params.Diagnostics) != 1 {
+		t.Fatalf("expected a single diagnostic but got %s", messages[0].Params)
+	}
+	if start := params.Diagnostics[0].Range.Start; start != (position{Line: 1, Character: 6}) {
+		t.Errorf("expected diagnostic to start at character 6 but got %+v", start)
+	}
+
+	tests := []struct {
+		request  string
+		expected string
+	}{
+		{
+			positionRequest(1, "textDocument/hover", 1, 4),
+			"{\"contents\":{\"kind\":\"markdown\",\"value\":\"```\\n$😀 int\\n```\"},\"range\":{\"start\":{\"line\":1,\"character\":2},\"end\":{\"line\":1,\"character\":5}}}",
+		},
+		{
+			positionRequest(1, "textDocument/definition", 1, 4),
+			`{"uri":"file:///test.pala","range":{"start":{"line":0,"character":0},"end":{"line":0,"character":3}}}`,
+		},
+	}
+	for _, tt := range tests {
+		messages := session(t, didOpen(text), tt.request)
+		if string(messages[1].Result) != tt.expected {
+			t.Errorf("expected result\n%s\nbut got\n%s", tt.expected, messages[1].Result)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package pala
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Option is a value of type T that may be absent. Operators use it for optional operands: an Option parameter accepts
+// a value of type T, the nil literal, or no operand at all when it is one of the last parameters.
+type Option[T any] struct {
+	Value T
+	Valid bool // Whether the value is present.
+}
+
+// Some returns an Option holding the given value.
+func Some[T any](value T) Option[T] {
+	return Option[T]{Value: value, Valid: true}
+}
+
+// None returns an absent Option.
+func None[T any]() Option[T] {
+	return Option[T]{}
+}
+
+// Get returns the value and whether it is present.
+func (o Option[T]) Get() (T, bool) {
+	return o.Value, o.Valid
+}
+
+// OrElse returns the value if it is present, and the given fallback otherwise.
+func (o Option[T]) OrElse(fallback T) T {
+	if o.Valid {
+		return o.Value
+	}
+	return fallback
+}
+
+func (o Option[T]) valueType() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+func (o Option[T]) wrap(value interface{}) interface{} {
+	if value == nil {
+		return Some(*new(T))
+	}
+	return Some(value.(T))
+}
+
+// optional is implemented by all Option types, so that the type checker can recognize them.
+type optional interface {
+	valueType() reflect.Type
+	wrap(value interface{}) interface{}
+}
+
+var optionalType = reflect.TypeOf((*optional)(nil)).Elem()
+
+// isOption reports whether the given type is an Option type.
+func isOption(t reflect.Type) bool {
+	return t != nil && t.Kind() == reflect.Struct && t.Implements(optionalType)
+}
+
+// acceptsNil reports whether the nil literal can be given where a value of the given type is expected.
+func acceptsNil(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Interface, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+		return true
+	default:
+		return isOption(t)
+	}
+}
+
+// optionNode creates an astNode that wraps the value of the given node in an Option of the given type.
+func optionNode[C any](node astNode[C], optionType reflect.Type) astNode[C] {
+	option := reflect.Zero(optionType).Interface().(optional)
+	return astNode[C]{
+		returnType: optionType,
+		evaluate: func(rt *runtime[C]) interface{} {
+			return option.wrap(node.evaluate(rt))
+		},
+	}
+}
+
+// zeroNode creates an astNode that evaluates to the zero value of the given type, such as nil for pointers or an absent
+// Option.
+func zeroNode[C any](returnType reflect.Type) astNode[C] {
+	return valueNode[C](returnType, reflect.Zero(returnType).Interface())
+}
+
+// checkNil returns an error if a list or map contains the nil literal while its elements do not accept nil. They are
+// checked when their type is final, as a list such as `[1 nil]` may still become a list of Options.
+func checkNil[C any](node astNode[C]) error {
+	if (node.kind == nodeList || node.kind == nodeMap) && node.returnType != nil {
+		for _, element := range node.children {
+			if element.kind == nodeNil && !acceptsNil(node.returnType.Elem()) {
+				if node.kind == nodeMap {
+					return fmt.Errorf("map of %s values cannot contain nil", node.returnType.Elem())
+				}
+				return fmt.Errorf("list of %s cannot contain nil", node.returnType.Elem())
+			}
+		}
+	}
+	for _, child := range node.children {
+		if err := checkNil(child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
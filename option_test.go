@@ -0,0 +1,98 @@
+package pala
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type greeter interface {
+	greet() string
+}
+
+func TestOption(t *testing.T) {
+	if value, ok := Some(3).Get(); value != 3 || !ok {
+		t.Errorf("expected present value 3 but got %v, %t", value, ok)
+	}
+	if value, ok := None[int]().Get(); value != 0 || ok {
+		t.Errorf("expected absent value but got %v, %t", value, ok)
+	}
+	if value := None[string]().OrElse("default"); value != "default" {
+		t.Errorf("expected fallback but got %s", value)
+	}
+}
+
+func TestProgram_Nil(t *testing.T) {
+	lang := NewLanguage[*context]()
+	lang.BindOperator("greet", func(name string, greeting Option[string]) string {
+		return greeting.OrElse("hello") + " " + name
+	})
+	lang.BindOperator("repeat", func(s string, times Option[int], separator Option[string]) string {
+		return strings.Repeat(s+separator.OrElse(""), times.OrElse(1))
+	})
+	lang.BindOperator("describe", func(u *user) string {
+		if u == nil {
+			return "nobody"
+		}
+		return u.Name
+	})
+	lang.BindOperator("show", func(g greeter) string { return fmt.Sprint(g == nil) })
+	lang.BindOperator("count", func(xs []Option[int]) int {
+		n := 0
+		for _, x := range xs {
+			if x.Valid {
+				n++
+			}
+		}
+		return n
+	})
+	lang.BindOperator("present", func(m map[string]Option[int]) int {
+		n := 0
+		for _, x := range m {
+			if x.Valid {
+				n++
+			}
+		}
+		return n
+	})
+	lang.BindOperator("keys", func(m map[string]*user) int { return len(m) })
+	lang.BindOperator("sum", func(xs []int) int { return len(xs) })
+	lang.BindOperator("print", func(v interface{}) string { return fmt.Sprint(v) })
+	lang.BindOperator("+", plus)
+	lang.BindLiteralEvaluator(ParseInt)
+	lang.BindLiteralEvaluator(ParseString)
+
+	tests := []evalCase{
+		{"omitted option", "greet ann", "hello ann", ""},
+		{"given option", "greet ann hi", "hi ann", ""},
+		{"nil option", "greet ann nil", "hello ann", ""},
+		{"several omitted options", "repeat ab", "ab", ""},
+		{"some options", "repeat ab 2", "abab", ""},
+		{"all options", "repeat ab 2 -", "ab-ab-", ""},
+		{"option from variable", "$n + 1 2\nrepeat a $n", "aaa", ""},
+		{"nil pointer", "describe nil", "nobody", ""},
+		{"nil interface", "show nil", "true", ""},
+		{"list of options", "count [1 nil 3]", 2, ""},
+		{"map with nil values", "keys {a: nil b: nil}", 2, ""},
+		{"nil slice", "sum nil", 0, ""},
+		{"empty list as interface", "print []", "[]", ""},
+		{"empty map as interface", "print {}", "map[]", ""},
+		{"nested untyped list as interface", "print [[] nil]", "[[] <nil>]", ""},
+		{"assigned map of nil values", "$m {a: nil}\nprint $m", "map[a:<nil>]", ""},
+		{"assigned empty list as interface", "$l []\nprint $l", "[]", ""},
+		{"assigned empty list as slice", "$l []\nsum $l", 0, ""},
+		{"assigned list of nil as slice", "$l [nil]\nsum $l", nil, "[line 1] operand 0 of operator sum expects []int but got []interface {}"},
+		{"too few operands", "repeat", nil, "[line 0] operator repeat expected 1 to 3 operands but got 0"},
+		{"too many operands", "greet ann hi there", nil, "[line 0] operator greet expected 1 to 2 operands but got 3"},
+		{"option of wrong type", "repeat ab x", nil, `[line 0] operand 1 of operator repeat expects pala.Option[int] but got string (pala.ParseInt: strconv.ParseInt: parsing "x": invalid syntax)`},
+		{"nil for value type", "+ 1 nil", nil, "[line 0] operand 1 of operator + expects int but got nil"},
+		{"nil in list of values", "sum [1 nil]", nil, "[line 0] list of int cannot contain nil"},
+		{"nil in assigned list", "$xs [1 nil]", nil, "[line 0] list of int cannot contain nil"},
+		{"assigned nil", "$a nil", nil, "[line 0] cannot assign nil without a type"},
+		{"nil in map of values", "$m {a: 1 b: nil}", nil, "[line 0] map of int values cannot contain nil"},
+		{"map of options", "present {a: 1 b: nil c: 3}", 2, ""},
+		{"nil map key", "keys {nil: 1}", nil, "[line 0] map key cannot be nil"},
+	}
+
+	runCases(t, lang, tests)
+}
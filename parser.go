@@ -1,8 +1,15 @@
 package pala
 
 import (
+	"bytes"
 	"fmt"
+	"io/fs"
+	"path"
 	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 type Parser[C any] struct {
@@ -10,28 +17,144 @@ type Parser[C any] struct {
 	language         *Language[C]
 	currToken        token
 	program          Program[C]
-	definedVariables map[string]reflect.Type
+	definedVariables map[string]VariableInfo
+	parsedVariables  map[string]VariableInfo
+	config           parserConfig
+	file             string       // Path of the imported file that is parsed, empty for the main input.
+	imports          *importState // Shared by the parser of the main input and the parsers of imported files.
 }
 
-func NewParser[C any](lexer Lexer, language *Language[C]) *Parser[C] {
+// importState tracks the files that are imported while parsing a program.
+type importState struct {
+	stack    []string // Files that are currently being parsed, outermost first.
+	imported map[string]bool
+}
+
+// ParserOption configures a Parser.
+type ParserOption func(config *parserConfig)
+
+type parserConfig struct {
+	allowedCapabilities map[string]bool // nil if all capabilities are allowed.
+	importFS            fs.FS           // nil if import statements are not supported.
+	mixedLists          bool
+}
+
+// WithMixedLists allows lists with elements of different types. The type of such a list is a slice of the narrowest
+// interface that all elements implement and that an operator of the language accepts, or `[]any` if there is none.
+// A list that is passed directly to an operator takes the slice type of the parameter if all elements fit.
+func WithMixedLists() ParserOption {
+	return func(config *parserConfig) {
+		config.mixedLists = true
+	}
+}
+
+// WithAllowedCapabilities restricts the parser to operators whose capabilities are all in the given set. Scripts that
+// use any other operator are rejected with a ParseError. Without this option all operators are allowed.
+func WithAllowedCapabilities(capabilities ...string) ParserOption {
+	return func(config *parserConfig) {
+		config.allowedCapabilities = make(map[string]bool, len(capabilities))
+		for _, capability := range capabilities {
+			config.allowedCapabilities[capability] = true
+		}
+	}
+}
+
+// VariableInfo describes a variable that is defined by a parsed program.
+type VariableInfo struct {
+	Name string
+	Type reflect.Type
+	Pos  Position // Position of the first assignment to the variable.
+
+	kind nodeKind // Kind of the assigned literal, which lets an untyped empty list or map be passed as an interface.
+}
+
+// ParseError is returned when the parser encounters invalid input.
+type ParseError struct {
+	Pos Position
+	Msg string
+	Err error // The error that caused the parse error, if any.
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s %s", e.Pos.prefix(), e.Msg)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+func NewParser[C any](lexer Lexer, language *Language[C], options ...ParserOption) *Parser[C] {
+	var config parserConfig
+	for _, option := range options {
+		option(&config)
+	}
+
 	parser := &Parser[C]{
+		config:   config,
 		lexer:    lexer,
 		language: language,
 		program: Program[C]{
 			variables: make(map[string]interface{}),
 		},
-		definedVariables: make(map[string]reflect.Type),
+		definedVariables: make(map[string]VariableInfo),
+		parsedVariables:  make(map[string]VariableInfo),
 	}
 	parser.advance()
 	return parser
 }
 
+// WithImportFS enables import statements, which are resolved through the given file system:
+//
+//	import "shared/definitions.pala"
+//
+// The imported file is parsed with the same Language and runs at the position of the import statement. Its variables
+// are visible to the rest of the program. Paths are relative to the importing file, or to the root of the file system
+// for the main input. A file that is imported more than once is only included the first time.
+func WithImportFS(fsys fs.FS) ParserOption {
+	return func(config *parserConfig) {
+		config.importFS = fsys
+	}
+}
+
+// Reset points the parser to a new lexer, keeping all variables that were defined by earlier successful calls to Parse.
+// Programs returned by subsequent calls to Parse share their variables with the earlier programs, which allows input to
+// be parsed and run incrementally.
+func (p *Parser[C]) Reset(lexer Lexer) {
+	p.lexer = lexer
+	p.definedVariables = copyVariables(p.parsedVariables)
+	p.advance()
+}
+
+// Variables returns the variables defined so far, sorted by name.
+func (p *Parser[C]) Variables() []VariableInfo {
+	var variables []VariableInfo
+	for _, variable := range p.definedVariables {
+		variables = append(variables, variable)
+	}
+	sort.Slice(variables, func(i, j int) bool { return variables[i].Name < variables[j].Name })
+	return variables
+}
+
 func (p *Parser[C]) advance() {
 	p.currToken = p.lexer.nextToken()
 }
 
 // Parse runs the parser, returning either the root node of the AST or a parse error.
 func (p *Parser[C]) Parse() (Program[C], error) {
+	p.imports = &importState{imported: make(map[string]bool)}
+	statements, err := p.parseStatements()
+	if err != nil {
+		return Program[C]{}, err
+	}
+
+	p.program.root = rootNode[C](statements)
+	p.parsedVariables = copyVariables(p.definedVariables)
+
+	return p.program, nil
+}
+
+// parseStatements parses all statements until the end of the input.
+func (p *Parser[C]) parseStatements() ([]astNode[C], error) {
 	var statements []astNode[C]
 
 parse:
@@ -42,20 +165,24 @@ parse:
 
 			expr, err := p.parseExpression()
 			if err != nil {
-				return Program[C]{}, err
+				return nil, err
 			}
 
 			node, err := p.writeVariable(variableToken, expr)
 			if err != nil {
-				return Program[C]{}, err
+				return nil, err
 			}
 
 			statements = append(statements, node)
 
 		case tokenLiteral:
-			node, err := p.parseOperation()
+			parse := p.parseOperation
+			if p.currToken.value == "import" && p.config.importFS != nil {
+				parse = p.parseImport
+			}
+			node, err := parse()
 			if err != nil {
-				return Program[C]{}, err
+				return nil, err
 			}
 			statements = append(statements, node)
 
@@ -65,15 +192,86 @@ parse:
 		case tokenComment, tokenNewline:
 
 		default:
-			return Program[C]{}, fmtTokenErr(p.currToken, fmt.Sprintf("encountered illegal token %s", p.currToken.value))
+			return nil, fmtTokenErr(p.currToken, fmt.Sprintf("encountered illegal token %s", p.currToken.value))
 		}
 
 		p.advance()
 	}
 
-	p.program.root = rootNode[C](statements)
+	return statements, nil
+}
 
-	return p.program, nil
+// parseImport parses the file named by an import statement, returning an astNode that runs its statements.
+func (p *Parser[C]) parseImport() (astNode[C], error) {
+	importToken := p.currToken
+	p.advance()
+	pathToken := p.currToken
+	if pathToken.tpe != tokenLiteral {
+		return astNode[C]{}, fmtTokenErr(importToken, "import expects a file path")
+	}
+	p.advance()
+	if p.currToken.tpe != tokenNewline && p.currToken.tpe != tokenComment && p.currToken.tpe != tokenEOF {
+		return astNode[C]{}, fmtTokenErr(p.currToken, fmt.Sprintf("unexpected %s after import", p.currToken.value))
+	}
+
+	name := pathToken.value
+	if len(name) >= 2 && strings.HasPrefix(name, `"`) && strings.HasSuffix(name, `"`) {
+		name = name[1 : len(name)-1]
+	}
+	file := path.Join(path.Dir(p.file), name)
+	source := file
+	if importToken.pos.Source != "" {
+		source = path.Join(path.Dir(importToken.pos.Source), name)
+	}
+
+	node, err := p.importFile(file, source)
+	if err != nil {
+		// The error is reported at the import statement and wraps the error of the imported file, which has the
+		// position within that file.
+		return astNode[C]{}, &ParseError{Pos: importToken.pos, Msg: err.Error(), Err: err}
+	}
+	node.start, node.end = importToken.pos, pathToken.end()
+	return node, nil
+}
+
+// importFile parses an imported file with a parser that shares the variables of this parser. The source is the name of
+// the file in positions and error messages.
+func (p *Parser[C]) importFile(file, source string) (astNode[C], error) {
+	for i, importing := range p.imports.stack {
+		if importing == file {
+			cycle := append(append([]string{}, p.imports.stack[i:]...), file)
+			return astNode[C]{}, fmt.Errorf("import cycle %s", strings.Join(cycle, " -> "))
+		}
+	}
+	if p.imports.imported[file] {
+		return nilNode[C](), nil
+	}
+
+	data, err := fs.ReadFile(p.config.importFS, file)
+	if err != nil {
+		return astNode[C]{}, fmt.Errorf("cannot import %s: %w", file, err)
+	}
+
+	parser := &Parser[C]{
+		lexer:            NewLexer(bytes.NewReader(data), WithSource(source)),
+		language:         p.language,
+		program:          p.program,
+		definedVariables: p.definedVariables,
+		config:           p.config,
+		file:             file,
+		imports:          p.imports,
+	}
+	parser.advance()
+
+	p.imports.stack = append(p.imports.stack, file)
+	statements, err := parser.parseStatements()
+	p.imports.stack = p.imports.stack[:len(p.imports.stack)-1]
+	if err != nil {
+		return astNode[C]{}, err
+	}
+
+	p.imports.imported[file] = true
+	return importNode[C](statements), nil
 }
 
 // parseExpression constructs an astNode to be assigned to a variable.
@@ -86,10 +284,20 @@ func (p *Parser[C]) parseExpression() (astNode[C], error) {
 			return p.readVariable(p.currToken)
 
 		case tokenLiteral:
+			if _, isOperator := p.language.lookupOperator(p.currToken.value); p.currToken.value == "nil" && !isOperator {
+				// A variable has the type of its value, which nil does not have.
+				return astNode[C]{}, fmtTokenErr(p.currToken, "cannot assign nil without a type")
+			}
 			return p.parseOperation()
 
 		case tokenLBracket:
-			return p.parseList()
+			return p.parseList(nil)
+
+		case tokenLBrace:
+			return p.parseMap(nil)
+
+		case tokenTupleStart:
+			return p.parseTuple(nil)
 
 		case tokenEOF:
 			return astNode[C]{}, fmtTokenErr(p.currToken, "unexpected end of expression")
@@ -104,10 +312,23 @@ func (p *Parser[C]) parseExpression() (astNode[C], error) {
 func (p *Parser[C]) parseOperation() (astNode[C], error) {
 	operator := p.currToken
 	multiLine := false
+	end := operator.end()
 
 	p.advance()
 
 	var operands []astNode[C]
+	var names []string    // The name of each operand, empty for positional operands.
+	var pendingName token // A name such as `width=` that is followed by its value in the next token.
+	var position int      // The index of the next positional operand in the operands of the operator.
+	info, _ := p.language.Operator(operator.value)
+
+	// expectedType returns the type the operator expects for the next operand, or nil if it is unknown.
+	expectedType := func() reflect.Type {
+		if pendingName.value != "" {
+			return info.namedOperandType(pendingName.value)
+		}
+		return info.operandType(position)
+	}
 
 	for {
 		switch p.currToken.tpe {
@@ -121,7 +342,11 @@ func (p *Parser[C]) parseOperation() (astNode[C], error) {
 			if !multiLine {
 				return astNode[C]{}, fmtTokenErr(p.currToken, fmt.Sprintf("invalid closing parenthesis"))
 			}
+			if pendingName.value != "" {
+				return astNode[C]{}, fmtTokenErr(pendingName, fmt.Sprintf("missing value for operand %s", pendingName.value))
+			}
 			multiLine = false
+			end = p.currToken.end()
 
 		case tokenVariable:
 			variable, err := p.readVariable(p.currToken)
@@ -129,137 +354,548 @@ func (p *Parser[C]) parseOperation() (astNode[C], error) {
 				return astNode[C]{}, err
 			}
 			operands = append(operands, variable)
+			end = variable.end
 
 		case tokenLiteral:
-			node, err := p.language.parseLiteral(p.currToken)
+			name, value, isNamed := cutOperandName(p.currToken)
+			if !isNamed || !info.acceptsNames() || pendingName.value != "" {
+				value = p.currToken
+			} else if value.value == "" {
+				pendingName = token{tpe: tokenLiteral, pos: p.currToken.pos, value: name}
+				break
+			} else {
+				pendingName = token{tpe: tokenLiteral, pos: p.currToken.pos, value: name}
+			}
+
+			var node astNode[C]
+			var err error
+			if value.tpe == tokenVariable {
+				node, err = p.readVariable(value)
+			} else {
+				node, err = p.parseLiteralAs(value, expectedType())
+			}
 			if err != nil {
 				return astNode[C]{}, err
 			}
 			operands = append(operands, node)
+			end = node.end
 
 		case tokenLBracket:
-			node, err := p.parseList()
+			node, err := p.parseList(expectedType())
 			if err != nil {
 				return astNode[C]{}, err
 			}
 			operands = append(operands, node)
+			end = node.end
+
+		case tokenLBrace:
+			node, err := p.parseMap(expectedType())
+			if err != nil {
+				return astNode[C]{}, err
+			}
+			operands = append(operands, node)
+			end = node.end
+
+		case tokenTupleStart:
+			tupleType := expectedType()
+			if pendingName.value == "" && (tupleType == nil || tupleType.Kind() != reflect.Struct) {
+				tupleType = info.spreadType(position)
+			}
+			node, err := p.parseTuple(tupleType)
+			if err != nil {
+				return astNode[C]{}, err
+			}
+			operands = append(operands, node)
+			end = node.end
 
 		case tokenNewline:
 			if multiLine {
 				break
 			}
-			node, err := p.language.parseOperation(operator, operands)
-			if err != nil {
-				return astNode[C]{}, err
+			if pendingName.value != "" {
+				return astNode[C]{}, fmtTokenErr(pendingName, fmt.Sprintf("missing value for operand %s", pendingName.value))
 			}
-			return node, nil
+			return p.buildOperation(operator, operands, names, end)
 
 		case tokenEOF:
 			if multiLine {
 				return astNode[C]{}, fmtTokenErr(p.currToken, fmt.Sprintf("missing closing parenthesis"))
 			}
-			node, err := p.language.parseOperation(operator, operands)
-			if err != nil {
-				return astNode[C]{}, err
+			if pendingName.value != "" {
+				return astNode[C]{}, fmtTokenErr(pendingName, fmt.Sprintf("missing value for operand %s", pendingName.value))
 			}
-			return node, nil
+			return p.buildOperation(operator, operands, names, end)
 
 		default:
 			return astNode[C]{}, fmtTokenErr(p.currToken, fmt.Sprintf("encountered illegal token %s", p.currToken.value))
 		}
 
+		if len(operands) > len(names) {
+			if pendingName.value == "" && len(names) > 0 && names[len(names)-1] != "" {
+				return astNode[C]{}, fmtTokenErr(p.currToken, "positional operand after named operand")
+			}
+			if pendingName.value == "" {
+				position += operandWidth(operands[len(operands)-1], info.operandType(position))
+			}
+			names = append(names, pendingName.value)
+			pendingName = token{}
+		}
+
 		p.advance()
 	}
 }
 
-// parseList constructs an astNode that constructs a list literal.
-func (p *Parser[C]) parseList() (astNode[C], error) {
-	var elementType reflect.Type
+// operandWidth returns the number of operands of an operator that a positional operand fills: the elements of a tuple
+// are spread over the operands unless the tuple is given for a struct.
+func operandWidth[C any](operand astNode[C], operandType reflect.Type) int {
+	if operand.kind == nodeTuple && (operandType == nil || operandType.Kind() != reflect.Struct) {
+		return len(operand.children)
+	}
+	return 1
+}
+
+// cutOperandName splits a named operand such as `width=80` into its name and a token for its value, which is empty if
+// the value is given as the next token.
+func cutOperandName(operand token) (string, token, bool) {
+	name, value, found := strings.Cut(operand.value, "=")
+	if !found || !isOperandName(name) {
+		return "", token{}, false
+	}
+
+	valueToken := token{tpe: tokenLiteral, pos: operand.pos, value: value}
+	valueToken.pos.Col += utf8.RuneCountInString(name) + 1
+	if strings.HasPrefix(value, "$") {
+		valueToken.tpe = tokenVariable
+	}
+	return name, valueToken, true
+}
+
+func isOperandName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		if !(r == '_' || unicode.IsLetter(r) || (i > 0 && unicode.IsDigit(r))) {
+			return false
+		}
+	}
+	return true
+}
+
+// buildOperation constructs the astNode for an operator applied to its operands.
+func (p *Parser[C]) buildOperation(operator token, operands []astNode[C], names []string, end Position) (astNode[C], error) {
+	if err := p.checkCapabilities(operator); err != nil {
+		return astNode[C]{}, err
+	}
+
+	node, err := p.language.parseOperation(operator, operands, names)
+	if err != nil {
+		return astNode[C]{}, fmtTokenErr(operator, err.Error())
+	}
+	node.start, node.end = operator.pos, end
+	node.children = operands
+	return node, nil
+}
+
+// checkCapabilities returns an error if the operator requires a capability that is not allowed.
+func (p *Parser[C]) checkCapabilities(operator token) error {
+	if p.config.allowedCapabilities == nil {
+		return nil
+	}
+	info, _ := p.language.Operator(operator.value)
+	for _, capability := range info.Capabilities {
+		if !p.config.allowedCapabilities[capability] {
+			return fmtTokenErr(operator, fmt.Sprintf("operator %s requires capability %s, which is not allowed", operator.value, capability))
+		}
+	}
+	return nil
+}
+
+// parseLiteral constructs an astNode for a literal using the literal evaluators of the Language. The literal `nil` is
+// not passed to the evaluators, it is the absent value of pointers, interfaces, maps, slices and Options.
+func (p *Parser[C]) parseLiteral(literal token) (astNode[C], error) {
+	return p.parseLiteralAs(literal, nil)
+}
+
+// parseLiteralAs constructs an astNode for a literal that is given where the expected type is expected, preferring the
+// literal evaluators that return values of that type.
+func (p *Parser[C]) parseLiteralAs(literal token, expectedType reflect.Type) (astNode[C], error) {
+	if literal.value == "nil" {
+		node := nilNode[C]()
+		node.start, node.end, node.kind = literal.pos, literal.end(), nodeNil
+		return node, nil
+	}
+
+	node, err := p.language.parseLiteralAs(literal, expectedType)
+	if err != nil {
+		return astNode[C]{}, &ParseError{Pos: literal.pos, Msg: err.Error(), Err: err}
+	}
+	node.start, node.end = literal.pos, literal.end()
+	return node, nil
+}
+
+// parseList constructs an astNode that constructs a list literal. The elements are evaluated as elements of the expected
+// type, if it is a slice or array type.
+func (p *Parser[C]) parseList(expectedType reflect.Type) (astNode[C], error) {
+	expectedElement := elementTypeOf(expectedType)
+	var elementTypes []reflect.Type // The distinct types of the elements, in order of appearance.
+	var mismatch token              // The first element of another type than the first element.
 	var values []astNode[C]
+	start := p.currToken.pos
 
 	p.advance()
 
 	for {
+		var node astNode[C]
+		var err error
+
 		switch p.currToken.tpe {
 		case tokenLiteral:
-			node, err := p.language.parseLiteral(p.currToken)
+			node, err = p.parseLiteralAs(p.currToken, expectedElement)
+
+		case tokenLBracket:
+			node, err = p.parseList(expectedElement)
+
+		case tokenLBrace:
+			node, err = p.parseMap(expectedElement)
+
+		case tokenTupleStart:
+			node, err = p.parseTuple(expectedElement)
+
+		case tokenRBracket:
+			elementType, err := p.listElementType(elementTypes, values, mismatch)
 			if err != nil {
 				return astNode[C]{}, err
 			}
+			node := nilNode[C]()
+			if elementType != nil {
+				node = sliceNode[C](reflect.SliceOf(elementType), start, values)
+			}
+			node.start, node.end = start, p.currToken.end()
+			node.children = values
+			node.kind = nodeList
+			return node, nil
 
-			if elementType != nil && elementType != node.returnType {
-				return astNode[C]{}, fmtTokenErr(p.currToken, "list must contain a single type")
+		case tokenEOF, tokenNewline:
+			return astNode[C]{}, fmtTokenErr(p.currToken, "unexpected end of list")
+
+		default:
+			return astNode[C]{}, fmtTokenErr(p.currToken, fmt.Sprintf("unexpected list element %s", p.currToken.value))
+		}
+
+		if err != nil {
+			return astNode[C]{}, err
+		}
+
+		if node.returnType != nil && !containsType(elementTypes, node.returnType) {
+			if len(elementTypes) == 1 {
+				mismatch = p.currToken
 			}
+			elementTypes = append(elementTypes, node.returnType)
+		}
+
+		values = append(values, node)
+		p.advance()
+	}
+}
+
+// elementTypeOf returns the element type of a slice or array type, or nil for other types.
+func elementTypeOf(t reflect.Type) reflect.Type {
+	if t == nil || (t.Kind() != reflect.Slice && t.Kind() != reflect.Array) {
+		return nil
+	}
+	return t.Elem()
+}
+
+// fieldTypeOf returns the type of the i-th field of a struct type, or nil for other types.
+func fieldTypeOf(t reflect.Type, i int) reflect.Type {
+	if t == nil || t.Kind() != reflect.Struct || i >= t.NumField() {
+		return nil
+	}
+	return t.Field(i).Type
+}
+
+// listElementType returns the element type of a list with elements of the given types. Elements of different types
+// are converted to the first of their types that all elements can be converted to, which replaces them in values.
+// Otherwise, a list with mixed elements is only allowed when the parser allows mixed lists.
+func (p *Parser[C]) listElementType(elementTypes []reflect.Type, values []astNode[C], mismatch token) (reflect.Type, error) {
+	if len(elementTypes) <= 1 {
+		if len(elementTypes) == 0 {
+			return nil, nil
+		}
+		return elementTypes[0], nil
+	}
 
-			if elementType == nil {
-				elementType = node.returnType
+	for _, candidate := range elementTypes {
+		converted := make([]astNode[C], len(values))
+		for i, value := range values {
+			if value.returnType == nil {
+				converted[i] = value
+				continue
 			}
+			var ok bool
+			if converted[i], ok = p.language.assignable(value, candidate); !ok {
+				converted = nil
+				break
+			}
+		}
+		if converted != nil {
+			copy(values, converted)
+			return candidate, nil
+		}
+	}
+
+	if !p.config.mixedLists {
+		return nil, fmtTokenErr(mismatch, "list must contain a single type")
+	}
+	return p.language.commonInterface(elementTypes), nil
+}
+
+func containsType(types []reflect.Type, t reflect.Type) bool {
+	for _, candidate := range types {
+		if candidate == t {
+			return true
+		}
+	}
+	return false
+}
 
-			values = append(values, node)
+// parseTuple constructs an astNode for a tuple literal such as `@(1 abc)`. Passed to an operator, a tuple fills a
+// struct parameter with its elements in field order, or it is spread over the positional parameters. Elsewhere it
+// evaluates to a struct with the fields T0, T1 and so on. The elements are evaluated as the fields of the expected type,
+// if it is a struct type.
+func (p *Parser[C]) parseTuple(expectedType reflect.Type) (astNode[C], error) {
+	start := p.currToken.pos
+	var elements []astNode[C]
+	var fields []reflect.StructField
+
+	p.advance()
+
+	for {
+		var node astNode[C]
+		var err error
+		fieldType := fieldTypeOf(expectedType, len(elements))
+
+		switch p.currToken.tpe {
+		case tokenLiteral:
+			node, err = p.parseLiteralAs(p.currToken, fieldType)
+
+		case tokenVariable:
+			node, err = p.readVariable(p.currToken)
 
 		case tokenLBracket:
-			node, err := p.parseList()
+			node, err = p.parseList(fieldType)
+
+		case tokenLBrace:
+			node, err = p.parseMap(fieldType)
+
+		case tokenTupleStart:
+			node, err = p.parseTuple(fieldType)
+
+		case tokenRParen:
+			if len(elements) == 0 {
+				return astNode[C]{}, fmtTokenErr(p.currToken, "tuple must have at least one element")
+			}
+			node := structNode[C](reflect.StructOf(fields), elements)
+			node.start, node.end = start, p.currToken.end()
+			node.children = elements
+			node.kind = nodeTuple
+			return node, nil
+
+		case tokenNewline, tokenComment:
+			p.advance()
+			continue
+
+		case tokenEOF:
+			return astNode[C]{}, fmtTokenErr(p.currToken, "unexpected end of tuple")
+
+		default:
+			return astNode[C]{}, fmtTokenErr(p.currToken, fmt.Sprintf("unexpected tuple element %s", p.currToken.value))
+		}
+
+		if err != nil {
+			return astNode[C]{}, err
+		}
+		if node.returnType == nil {
+			return astNode[C]{}, fmtTokenErr(p.currToken, "tuple element has no type")
+		}
+
+		fields = append(fields, reflect.StructField{Name: fmt.Sprintf("T%d", len(fields)), Type: node.returnType})
+		elements = append(elements, node)
+		p.advance()
+	}
+}
+
+// parseMap constructs an astNode that constructs a map literal such as `{a: 1 b: 2}`. Keys are literals followed by a
+// colon, values are literals, variables, lists or maps. Entries may be spread over multiple lines. Keys and values are
+// evaluated as those of the expected type, if it is a map type.
+func (p *Parser[C]) parseMap(expectedType reflect.Type) (astNode[C], error) {
+	var expectedKey, expectedValue reflect.Type
+	if expectedType != nil && expectedType.Kind() == reflect.Map {
+		expectedKey, expectedValue = expectedType.Key(), expectedType.Elem()
+	}
+	var keyType, valueType reflect.Type
+	var entries []astNode[C] // Alternately a key and its value.
+	keys := make(map[string]bool)
+	start := p.currToken.pos
+
+	p.advance()
+
+	for {
+		switch p.currToken.tpe {
+		case tokenLiteral:
+			keyToken, err := p.parseMapKey()
 			if err != nil {
 				return astNode[C]{}, err
 			}
+			if keys[keyToken.value] {
+				return astNode[C]{}, fmtTokenErr(keyToken, fmt.Sprintf("duplicate map key %s", keyToken.value))
+			}
+			keys[keyToken.value] = true
 
-			if elementType != nil && elementType != node.returnType {
-				return astNode[C]{}, fmtTokenErr(p.currToken, "list must contain a single type")
+			key, err := p.parseLiteralAs(keyToken, expectedKey)
+			if err != nil {
+				return astNode[C]{}, err
+			}
+			if key.kind == nodeNil {
+				return astNode[C]{}, fmtTokenErr(keyToken, "map key cannot be nil")
+			}
+			if keyType == nil {
+				if !key.returnType.Comparable() {
+					return astNode[C]{}, fmtTokenErr(keyToken, fmt.Sprintf("map keys of type %s are not comparable", key.returnType))
+				}
+				keyType = key.returnType
+			} else if keyType != key.returnType {
+				return astNode[C]{}, fmtTokenErr(keyToken, "map keys must have a single type")
 			}
 
-			if elementType == nil {
-				elementType = node.returnType
+			value, err := p.parseMapValue(keyToken, expectedValue)
+			if err != nil {
+				return astNode[C]{}, err
+			}
+			if valueType == nil {
+				valueType = value.returnType
+			} else if value.returnType != nil && valueType != value.returnType {
+				return astNode[C]{}, fmtTokenErr(p.currToken, "map values must have a single type")
 			}
 
-			values = append(values, node)
+			entries = append(entries, key, value)
 
-		case tokenRBracket:
-			if elementType == nil {
-				return nilNode[C](), nil
+		case tokenRBrace:
+			node := nilNode[C]()
+			if keyType != nil && valueType != nil {
+				node = mapNode[C](reflect.MapOf(keyType, valueType), start, entries)
 			}
+			node.start, node.end = start, p.currToken.end()
+			node.children = entries
+			node.kind = nodeMap
+			return node, nil
 
-			sliceType := reflect.SliceOf(elementType)
-			return sliceNode[C](sliceType, values), nil
+		case tokenNewline, tokenComment:
 
-		case tokenEOF, tokenNewline:
-			return astNode[C]{}, fmtTokenErr(p.currToken, "unexpected end of list")
+		case tokenEOF:
+			return astNode[C]{}, fmtTokenErr(p.currToken, "unexpected end of map")
 
 		default:
-			return astNode[C]{}, fmtTokenErr(p.currToken, fmt.Sprintf("unexpected list element %s", p.currToken.value))
+			return astNode[C]{}, fmtTokenErr(p.currToken, fmt.Sprintf("unexpected map key %s", p.currToken.value))
 		}
 
 		p.advance()
 	}
 }
 
+// parseMapKey reads a map key and the colon that follows it, which may be separated from the key by whitespace. It
+// returns the key without colon and leaves the parser at the start of the value.
+func (p *Parser[C]) parseMapKey() (token, error) {
+	key := p.currToken
+	p.advance()
+
+	if len(key.value) > 1 && strings.HasSuffix(key.value, ":") {
+		key.value = strings.TrimSuffix(key.value, ":")
+		return key, nil
+	}
+	if p.currToken.tpe != tokenLiteral || p.currToken.value != ":" {
+		return token{}, fmtTokenErr(key, fmt.Sprintf("expected : after map key %s", key.value))
+	}
+	p.advance()
+	return key, nil
+}
+
+// parseMapValue reads the value of a map entry.
+func (p *Parser[C]) parseMapValue(key token, expectedType reflect.Type) (astNode[C], error) {
+	switch p.currToken.tpe {
+	case tokenLiteral:
+		return p.parseLiteralAs(p.currToken, expectedType)
+	case tokenVariable:
+		return p.readVariable(p.currToken)
+	case tokenLBracket:
+		return p.parseList(expectedType)
+	case tokenLBrace:
+		return p.parseMap(expectedType)
+	case tokenTupleStart:
+		return p.parseTuple(expectedType)
+	default:
+		return astNode[C]{}, fmtTokenErr(key, fmt.Sprintf("missing value for map key %s", key.value))
+	}
+}
+
 // writeVariable writes a variable to the program variables.
 func (p *Parser[C]) writeVariable(variableName token, value astNode[C]) (astNode[C], error) {
-	p.definedVariables[variableName.value] = value.returnType
+	if i := strings.IndexAny(variableName.value, ".["); i >= 0 {
+		target := "field"
+		if variableName.value[i] == '[' {
+			target = "element"
+		}
+		return astNode[C]{}, fmtTokenErr(variableName, fmt.Sprintf("cannot assign to %s %s", target, variableName.value))
+	}
+
+	if err := checkNil(value); err != nil {
+		return astNode[C]{}, fmtTokenErr(variableName, err.Error())
+	}
+	if value.returnType == nil && len(value.children) > 0 {
+		// a list or map of only nil or empty elements holds interface values, as its elements do not determine a type.
+		if converted, ok := p.language.assignable(value, anyType); ok {
+			value = converted
+		}
+	}
+
+	variable, isDefined := p.definedVariables[variableName.value]
+	if !isDefined {
+		variable = VariableInfo{Name: variableName.value, Pos: variableName.pos}
+	}
+	variable.Type, variable.kind = value.returnType, nodeOther
+	if value.returnType == nil {
+		variable.kind = value.kind
+	}
+	p.definedVariables[variableName.value] = variable
+
+	target := astNode[C]{
+		returnType: value.returnType,
+		start:      variableName.pos,
+		end:        variableName.end(),
+	}
 
 	return astNode[C]{
 		returnType: nil,
-		evaluate: func(context C) interface{} {
-			p.program.variables[variableName.value] = value.evaluate(context)
+		evaluate: func(rt *runtime[C]) interface{} {
+			rt.variables[variableName.value] = value.evaluate(rt)
 			return nil
 		},
+		start:    variableName.pos,
+		end:      value.end,
+		children: []astNode[C]{target, value},
 	}, nil
 }
 
-// readVariable reads a variable from the program variables.
-func (p *Parser[C]) readVariable(variableName token) (astNode[C], error) {
-	varType, isDefined := p.definedVariables[variableName.value]
-	if !isDefined {
-		return astNode[C]{}, fmtTokenErr(variableName, fmt.Sprintf("encountered undeclared variable %s", variableName.value))
+func copyVariables(variables map[string]VariableInfo) map[string]VariableInfo {
+	result := make(map[string]VariableInfo, len(variables))
+	for name, variable := range variables {
+		result[name] = variable
 	}
-	return astNode[C]{
-		returnType: varType,
-		evaluate: func(context C) interface{} {
-			return p.program.variables[variableName.value]
-		},
-	}, nil
+	return result
 }
 
-// fmtTokenErr is used internally to return a message with line number.
+// fmtTokenErr is used internally to return a message with the position of the token.
 func fmtTokenErr(t token, msg string) error {
-	return fmt.Errorf("[line %d] %s", t.line, msg)
+	return &ParseError{Pos: t.pos, Msg: msg}
 }
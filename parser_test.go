@@ -1,8 +1,10 @@
 package pala
 
 import (
+	"errors"
 	"strings"
 	"testing"
+	"testing/fstest"
 )
 
 func Test_FailToParse(t *testing.T) {
@@ -14,7 +16,7 @@ func Test_FailToParse(t *testing.T) {
 		{
 			"operator with wrong argument count",
 			"+ 1",
-			"operator + expected 2 operands but got 1",
+			"[line 0] operator + expected 2 operands but got 1",
 		},
 		{
 			"missing closing parenthesis",
@@ -50,3 +52,206 @@ func Test_FailToParse(t *testing.T) {
 		})
 	}
 }
+
+func TestParser_Reset(t *testing.T) {
+	lang := NewLanguage[*context]()
+	lang.BindOperator("+", plus)
+	lang.BindLiteralEvaluator(ParseInt)
+
+	parser := NewParser(NewLexer(strings.NewReader("$a + 1 2")), lang)
+	prog, err := parser.Parse()
+	if err != nil {
+		t.Fatalf("expected program to be parsed:\n%s", err)
+	}
+	prog.Run(&context{})
+
+	parser.Reset(NewLexer(strings.NewReader("$b + 1 2\n$c +")))
+	if _, err := parser.Parse(); err == nil {
+		t.Fatalf("expected program to fail to be parsed but it succeeded")
+	}
+
+	parser.Reset(NewLexer(strings.NewReader("$c + $a $a")))
+	prog, err = parser.Parse()
+	if err != nil {
+		t.Fatalf("expected program to be parsed:\n%s", err)
+	}
+	prog.Run(&context{})
+
+	variables := parser.Variables()
+	if len(variables) != 2 || variables[0].Name != "$a" || variables[1].Name != "$c" {
+		t.Fatalf("expected variables $a and $c but got %+v", variables)
+	}
+	if value, _ := prog.Variable("$c"); value != 6 {
+		t.Errorf("expected $c to be 6 but got %v", value)
+	}
+}
+
+func TestParser_Positions(t *testing.T) {
+	lang := NewLanguage[*context]()
+	lang.BindOperator("+", plus)
+	lang.BindLiteralEvaluator(ParseInt)
+
+	parser := NewParser(NewLexer(strings.NewReader("$a + 1 2\n  $b + $a 3\n$a + $b $b\n+ $a x")), lang)
+	_, err := parser.Parse()
+
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("expected a parse error but got %v", err)
+	}
+	if parseErr.Pos != (Position{Line: 3, Col: 5}) {
+		t.Errorf("expected error at line 3, col 5 but got %+v", parseErr.Pos)
+	}
+
+	variables := parser.Variables()
+	if len(variables) != 2 {
+		t.Fatalf("expected 2 variables but got %d", len(variables))
+	}
+	if variables[0].Pos != (Position{Line: 0, Col: 0}) || variables[1].Pos != (Position{Line: 1, Col: 2}) {
+		t.Errorf("expected variables to be defined at their first assignment but got %+v", variables)
+	}
+}
+
+func TestParser_AllowedCapabilities(t *testing.T) {
+	lang := NewLanguage[*context]()
+	lang.BindOperator("+", plus)
+	lang.BindOperator("echo", echo, WithCapabilities("output"))
+	lang.BindOperator("*", mul, WithCapabilities("output", "network"))
+	lang.BindLiteralEvaluator(ParseInt)
+	lang.BindLiteralEvaluator(ParseString)
+
+	tests := []struct {
+		name           string
+		program        string
+		options        []ParserOption
+		expectedErrMsg string
+	}{
+		{"unrestricted", "echo hello\n* 1 2", nil, ""},
+		{"no capabilities required", "+ 1 2", []ParserOption{WithAllowedCapabilities()}, ""},
+		{"capability allowed", "echo hello", []ParserOption{WithAllowedCapabilities("output")}, ""},
+		{
+			"capability not allowed",
+			"+ 1 2\necho hello",
+			[]ParserOption{WithAllowedCapabilities()},
+			"[line 1] operator echo requires capability output, which is not allowed",
+		},
+		{
+			"one of several capabilities not allowed",
+			"* 1 2",
+			[]ParserOption{WithAllowedCapabilities("output")},
+			"[line 0] operator * requires capability network, which is not allowed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewParser(NewLexer(strings.NewReader(tt.program)), lang, tt.options...).Parse()
+			if tt.expectedErrMsg == "" {
+				if err != nil {
+					t.Fatalf("expected program to be parsed, got %s", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.expectedErrMsg {
+				t.Fatalf("expected error '%s' but got '%v'", tt.expectedErrMsg, err)
+			}
+		})
+	}
+}
+
+func TestParser_Import(t *testing.T) {
+	lang := NewLanguage[*context]()
+	lang.BindOperator("+", plus)
+	lang.BindLiteralEvaluator(ParseInt)
+
+	fsys := fstest.MapFS{
+		"main.pala":          {Data: []byte("import \"lib/numbers.pala\"\n$c + $a $b")},
+		"lib/numbers.pala":   {Data: []byte("import \"constants.pala\"\n$b + $a 1")},
+		"lib/constants.pala": {Data: []byte("$a + 1 1")},
+		"twice.pala":         {Data: []byte("import lib/constants.pala\nimport lib/constants.pala\n$a + $a 1\nimport lib/constants.pala")},
+		"cycle/a.pala":       {Data: []byte("import b.pala")},
+		"cycle/b.pala":       {Data: []byte("$x + 1 1\nimport a.pala")},
+		"broken.pala":        {Data: []byte("$a + 0 1\n\n+ $a")},
+		"nested.pala":        {Data: []byte("$a + 0 1\nimport broken.pala")},
+	}
+
+	tests := []struct {
+		name           string
+		program        string
+		expectedValue  interface{}
+		expectedErrMsg string
+	}{
+		{"import with relative paths", "import main.pala\n+ $c 0", 5, ""},
+		{"imported variables are visible", "import lib/numbers.pala\n+ $b 10", 13, ""},
+		{"files are included once", "import twice.pala\n+ $a 0", 3, ""},
+		{"cyclic import", "\nimport cycle/a.pala", nil, "[line 1] cycle/a.pala:1:1: cycle/b.pala:2:1: import cycle cycle/a.pala -> cycle/b.pala -> cycle/a.pala"},
+		{"missing file", "import missing.pala", nil, "[line 0] cannot import missing.pala: open missing.pala: file does not exist"},
+		{"error in imported file", "+ 1 2\nimport nested.pala", nil, "[line 1] nested.pala:2:1: broken.pala:3:1: operator + expected 2 operands but got 1"},
+		{"missing path", "import", nil, "[line 0] import expects a file path"},
+		{"unexpected operand", "import a.pala b.pala", nil, "[line 0] unexpected b.pala after import"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prog, err := NewParser(NewLexer(strings.NewReader(tt.program)), lang, WithImportFS(fsys)).Parse()
+			if tt.expectedErrMsg != "" {
+				if err == nil || err.Error() != tt.expectedErrMsg {
+					t.Fatalf("expected error '%s' but got '%v'", tt.expectedErrMsg, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected program to be parsed, got %s", err)
+			}
+
+			value, _, err := prog.Eval(&context{})
+			if err != nil {
+				t.Fatalf("expected program to run, got %s", err)
+			}
+			if value != tt.expectedValue {
+				t.Errorf("expected %v but got %v", tt.expectedValue, value)
+			}
+		})
+	}
+}
+
+func TestParser_ImportErrorChain(t *testing.T) {
+	lang := NewLanguage[*context]()
+	lang.BindOperator("+", plus)
+	lang.BindLiteralEvaluator(ParseInt)
+	fsys := fstest.MapFS{
+		"a.pala": {Data: []byte("import b.pala")},
+		"b.pala": {Data: []byte("\n+ 1 x")},
+	}
+
+	_, err := NewParser(NewLexer(strings.NewReader("import a.pala")), lang, WithImportFS(fsys)).Parse()
+
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || parseErr.Pos != (Position{}) {
+		t.Fatalf("expected a parse error at the import statement but got %v", err)
+	}
+	var literalErr *LiteralError
+	if !errors.As(err, &literalErr) || literalErr.Literal != "x" {
+		t.Fatalf("expected the literal error of the imported file but got %v", err)
+	}
+	innermost := parseErr
+	for {
+		var nested *ParseError
+		if !errors.As(innermost.Err, &nested) {
+			break
+		}
+		innermost = nested
+	}
+	if innermost.Pos != (Position{Line: 1, Col: 4, Source: "b.pala"}) {
+		t.Errorf("expected the innermost error at the literal but got %+v", innermost.Pos)
+	}
+}
+
+func TestParser_ImportDisabled(t *testing.T) {
+	lang := NewLanguage[*context]()
+	lang.BindLiteralEvaluator(ParseString)
+
+	_, err := NewParser(NewLexer(strings.NewReader("import lib.pala")), lang).Parse()
+	if err == nil || err.Error() != "[line 0] unknown operator import" {
+		t.Errorf("expected import to be an ordinary operator without import file system, got %v", err)
+	}
+}
@@ -0,0 +1,396 @@
+package pala
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"runtime/metrics"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Profiler is a Tracer that records how often operators and statements are evaluated, how long that takes and how
+// much memory is allocated. Pass it to Program.Run with WithTracer, after which the profile can be written as a text
+// report or in the pprof format.
+//
+// The cumulative values of a statement include the operator call it contains, while its self values exclude it.
+type Profiler struct {
+	mutex     sync.Mutex
+	started   time.Time
+	operators map[string]*ProfileEntry
+	lines     map[SourceLine]*ProfileEntry
+	samples   map[string]*profileSample
+	stack     []profileFrame
+}
+
+// ProfileEntry holds the statistics of an operator or a source line.
+type ProfileEntry struct {
+	Calls           int
+	CumulativeTime  time.Duration
+	SelfTime        time.Duration
+	CumulativeAlloc uint64
+	SelfAlloc       uint64
+}
+
+// profileFrame is a statement or operator call that is currently being evaluated.
+type profileFrame struct {
+	location   profileLocation
+	start      time.Time
+	startAlloc uint64
+	childTime  time.Duration
+	childAlloc uint64
+}
+
+// profileLocation identifies a statement or an operator call in the source.
+type profileLocation struct {
+	kind   TraceKind
+	symbol string
+	line   SourceLine
+}
+
+// profileSample holds the self values of a single stack of locations, as needed for the pprof format.
+type profileSample struct {
+	stack []profileLocation // leaf first
+	calls int64
+	time  time.Duration
+	alloc uint64
+}
+
+// NewProfiler constructs an empty Profiler.
+func NewProfiler() *Profiler {
+	return &Profiler{
+		started:   time.Now(),
+		operators: make(map[string]*ProfileEntry),
+		lines:     make(map[SourceLine]*ProfileEntry),
+		samples:   make(map[string]*profileSample),
+	}
+}
+
+func (p *Profiler) Enter(event TraceEvent) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	// Frames of nodes that did not exit, for instance because a run was aborted, are discarded.
+	if event.Depth < len(p.stack) {
+		p.stack = p.stack[:event.Depth]
+	}
+
+	p.stack = append(p.stack, profileFrame{
+		location:   profileLocation{kind: event.Kind, symbol: event.Symbol, line: event.Pos.SourceLine()},
+		start:      time.Now(),
+		startAlloc: allocatedBytes(),
+	})
+}
+
+func (p *Profiler) Exit(event TraceEvent) {
+	end := time.Now()
+	endAlloc := allocatedBytes()
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if len(p.stack) == 0 {
+		return
+	}
+	frame := p.stack[len(p.stack)-1]
+	p.stack = p.stack[:len(p.stack)-1]
+
+	cumulativeTime := end.Sub(frame.start)
+	cumulativeAlloc := endAlloc - frame.startAlloc
+	selfTime := cumulativeTime - frame.childTime
+	selfAlloc := cumulativeAlloc - frame.childAlloc
+
+	if len(p.stack) > 0 {
+		parent := &p.stack[len(p.stack)-1]
+		parent.childTime += cumulativeTime
+		parent.childAlloc += cumulativeAlloc
+	}
+
+	var entry *ProfileEntry
+	switch frame.location.kind {
+	case TraceOperator:
+		entry = p.operators[frame.location.symbol]
+		if entry == nil {
+			entry = &ProfileEntry{}
+			p.operators[frame.location.symbol] = entry
+		}
+	case TraceStatement:
+		entry = p.lines[frame.location.line]
+		if entry == nil {
+			entry = &ProfileEntry{}
+			p.lines[frame.location.line] = entry
+		}
+	}
+	entry.Calls++
+	entry.CumulativeTime += cumulativeTime
+	entry.SelfTime += selfTime
+	entry.CumulativeAlloc += cumulativeAlloc
+	entry.SelfAlloc += selfAlloc
+
+	stack := []profileLocation{frame.location}
+	for i := len(p.stack) - 1; i >= 0; i-- {
+		stack = append(stack, p.stack[i].location)
+	}
+	key := fmt.Sprint(stack)
+	sample := p.samples[key]
+	if sample == nil {
+		sample = &profileSample{stack: stack}
+		p.samples[key] = sample
+	}
+	sample.calls++
+	sample.time += selfTime
+	sample.alloc += selfAlloc
+}
+
+// Operators returns the statistics per operator symbol.
+func (p *Profiler) Operators() map[string]ProfileEntry {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	result := make(map[string]ProfileEntry, len(p.operators))
+	for symbol, entry := range p.operators {
+		result[symbol] = *entry
+	}
+	return result
+}
+
+// Lines returns the statistics of the statements per source line. Lines of different sources, such as imported files,
+// are kept apart.
+func (p *Profiler) Lines() map[SourceLine]ProfileEntry {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	result := make(map[SourceLine]ProfileEntry, len(p.lines))
+	for line, entry := range p.lines {
+		result[line] = *entry
+	}
+	return result
+}
+
+// WriteReport writes a text report of the profile, ordered by cumulative time.
+func (p *Profiler) WriteReport(w io.Writer) error {
+	operators := p.Operators()
+	symbols := make([]string, 0, len(operators))
+	for symbol := range operators {
+		symbols = append(symbols, symbol)
+	}
+	sort.Slice(symbols, func(i, j int) bool {
+		return operators[symbols[i]].CumulativeTime > operators[symbols[j]].CumulativeTime
+	})
+
+	lines := p.Lines()
+	sourceLines := make([]SourceLine, 0, len(lines))
+	for line := range lines {
+		sourceLines = append(sourceLines, line)
+	}
+	sort.Slice(sourceLines, func(i, j int) bool {
+		return lines[sourceLines[i]].CumulativeTime > lines[sourceLines[j]].CumulativeTime
+	})
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	_, _ = fmt.Fprintln(tw, "operator\tcalls\tcumulative\tself\tcumulative alloc\tself alloc\t")
+	for _, symbol := range symbols {
+		writeProfileEntry(tw, symbol, operators[symbol])
+	}
+	_, _ = fmt.Fprintln(tw, "\t\t\t\t\t\t")
+	_, _ = fmt.Fprintln(tw, "line\tcalls\tcumulative\tself\tcumulative alloc\tself alloc\t")
+	for _, line := range sourceLines {
+		writeProfileEntry(tw, line.String(), lines[line])
+	}
+	return tw.Flush()
+}
+
+func writeProfileEntry(w io.Writer, name string, entry ProfileEntry) {
+	_, _ = fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%dB\t%dB\t\n",
+		name, entry.Calls, entry.CumulativeTime, entry.SelfTime, entry.CumulativeAlloc, entry.SelfAlloc)
+}
+
+// WritePprof writes the profile in the gzip compressed protocol buffer format that is read by `go tool pprof`.
+// Statements are reported as the function "main" and operator calls as functions named after their symbol, with the
+// source of the statement or call as file name. Lines are reported one based, as is common for source files.
+func (p *Profiler) WritePprof(w io.Writer) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	table := newStringTable()
+	profile := &protoBuffer{}
+
+	for _, sampleType := range [][2]string{{"calls", "count"}, {"time", "nanoseconds"}, {"alloc_space", "bytes"}} {
+		profile.message(1, func(b *protoBuffer) {
+			b.int(1, table.index(sampleType[0]))
+			b.int(2, table.index(sampleType[1]))
+		})
+	}
+
+	keys := make([]string, 0, len(p.samples))
+	for key := range p.samples {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	locations := make(map[profileLocation]uint64)
+	functions := make(map[profileFunction]uint64)
+	var locationOrder []profileLocation
+	var functionOrder []profileFunction
+	for _, key := range keys {
+		sample := p.samples[key]
+		var locationIDs []uint64
+		for _, location := range sample.stack {
+			id, has := locations[location]
+			if !has {
+				id = uint64(len(locations) + 1)
+				locations[location] = id
+				locationOrder = append(locationOrder, location)
+			}
+			locationIDs = append(locationIDs, id)
+
+			function := location.function()
+			if _, has := functions[function]; !has {
+				functions[function] = uint64(len(functions) + 1)
+				functionOrder = append(functionOrder, function)
+			}
+		}
+
+		profile.message(2, func(b *protoBuffer) {
+			b.packedUints(1, locationIDs)
+			b.packedInts(2, []int64{sample.calls, int64(sample.time), int64(sample.alloc)})
+		})
+	}
+
+	for _, location := range locationOrder {
+		profile.message(4, func(b *protoBuffer) {
+			b.uint(1, locations[location])
+			b.message(4, func(line *protoBuffer) {
+				line.uint(1, functions[location.function()])
+				line.int(2, int64(location.line.Line+1))
+			})
+		})
+	}
+
+	for _, function := range functionOrder {
+		profile.message(5, func(b *protoBuffer) {
+			b.uint(1, functions[function])
+			b.int(2, table.index(function.name))
+			b.int(3, table.index(function.name))
+			b.int(4, table.index(function.filename))
+		})
+	}
+
+	profile.int(9, p.started.UnixNano())
+	profile.int(10, int64(time.Since(p.started)))
+	profile.int(14, table.index("time"))
+
+	for _, s := range table.values {
+		profile.string(6, s)
+	}
+
+	gz := gzip.NewWriter(w)
+	if _, err := gz.Write(profile.bytes); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// profileFunction identifies a function of a pprof profile. The same operator called from different sources is
+// reported as a function per source.
+type profileFunction struct {
+	name     string
+	filename string
+}
+
+func (l profileLocation) function() profileFunction {
+	if l.kind == TraceStatement {
+		return profileFunction{name: "main", filename: l.line.Source}
+	}
+	return profileFunction{name: l.symbol, filename: l.line.Source}
+}
+
+// allocatedBytes returns the cumulative number of bytes that were allocated on the heap.
+func allocatedBytes() uint64 {
+	sample := []metrics.Sample{{Name: "/gc/heap/allocs:bytes"}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return sample[0].Value.Uint64()
+}
+
+// stringTable holds the strings of a pprof profile, which are referenced by their index.
+type stringTable struct {
+	values  []string
+	indices map[string]int64
+}
+
+func newStringTable() *stringTable {
+	return &stringTable{values: []string{""}, indices: map[string]int64{"": 0}}
+}
+
+func (t *stringTable) index(s string) int64 {
+	if i, has := t.indices[s]; has {
+		return i
+	}
+	i := int64(len(t.values))
+	t.values = append(t.values, s)
+	t.indices[s] = i
+	return i
+}
+
+// protoBuffer encodes the protocol buffer wire format, as far as it is needed for pprof profiles.
+type protoBuffer struct {
+	bytes []byte
+}
+
+func (b *protoBuffer) varint(v uint64) {
+	for v >= 0x80 {
+		b.bytes = append(b.bytes, byte(v)|0x80)
+		v >>= 7
+	}
+	b.bytes = append(b.bytes, byte(v))
+}
+
+func (b *protoBuffer) key(field int, wireType uint64) {
+	b.varint(uint64(field)<<3 | wireType)
+}
+
+func (b *protoBuffer) uint(field int, v uint64) {
+	b.key(field, 0)
+	b.varint(v)
+}
+
+func (b *protoBuffer) int(field int, v int64) {
+	b.uint(field, uint64(v))
+}
+
+func (b *protoBuffer) lengthDelimited(field int, data []byte) {
+	b.key(field, 2)
+	b.varint(uint64(len(data)))
+	b.bytes = append(b.bytes, data...)
+}
+
+func (b *protoBuffer) string(field int, s string) {
+	b.lengthDelimited(field, []byte(s))
+}
+
+func (b *protoBuffer) message(field int, encode func(b *protoBuffer)) {
+	nested := &protoBuffer{}
+	encode(nested)
+	b.lengthDelimited(field, nested.bytes)
+}
+
+func (b *protoBuffer) packedUints(field int, values []uint64) {
+	packed := &protoBuffer{}
+	for _, v := range values {
+		packed.varint(v)
+	}
+	b.lengthDelimited(field, packed.bytes)
+}
+
+func (b *protoBuffer) packedInts(field int, values []int64) {
+	packed := &protoBuffer{}
+	for _, v := range values {
+		packed.varint(uint64(v))
+	}
+	b.lengthDelimited(field, packed.bytes)
+}
@@ -0,0 +1,135 @@
+package pala
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestProfiler(t *testing.T) {
+	prog := parseTraceProgram(t, "$a + 1 2\n$b * $a 4\n+ $a $b")
+
+	profiler := NewProfiler()
+	if err := prog.Run(&context{}, WithTracer(profiler)); err != nil {
+		t.Fatalf("expected program to run: %s", err)
+	}
+
+	operators := profiler.Operators()
+	if operators["+"].Calls != 2 {
+		t.Errorf("expected 2 calls of +, got %d", operators["+"].Calls)
+	}
+	if operators["*"].Calls != 1 {
+		t.Errorf("expected 1 call of *, got %d", operators["*"].Calls)
+	}
+
+	lines := profiler.Lines()
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 lines, got %d", len(lines))
+	}
+	for line, entry := range lines {
+		if entry.Calls != 1 {
+			t.Errorf("expected 1 call of line %s, got %d", line, entry.Calls)
+		}
+		if entry.SelfTime > entry.CumulativeTime || entry.SelfAlloc > entry.CumulativeAlloc {
+			t.Errorf("expected self values of line %s not to exceed cumulative values: %+v", line, entry)
+		}
+	}
+}
+
+func TestProfiler_WriteReport(t *testing.T) {
+	prog := parseTraceProgram(t, "+ 1 2\n* 3 4")
+
+	profiler := NewProfiler()
+	_ = prog.Run(&context{}, WithTracer(profiler))
+
+	var report bytes.Buffer
+	if err := profiler.WriteReport(&report); err != nil {
+		t.Fatalf("expected report to be written: %s", err)
+	}
+
+	for _, expected := range []string{"operator", "calls", "line", "+", "*"} {
+		if !strings.Contains(report.String(), expected) {
+			t.Errorf("expected report to contain %q, got\n%s", expected, report.String())
+		}
+	}
+}
+
+func TestProfiler_WritePprof(t *testing.T) {
+	prog := parseTraceProgram(t, "+ 1 2\n* 3 4")
+
+	profiler := NewProfiler()
+	_ = prog.Run(&context{}, WithTracer(profiler))
+
+	var profile bytes.Buffer
+	if err := profiler.WritePprof(&profile); err != nil {
+		t.Fatalf("expected profile to be written: %s", err)
+	}
+
+	reader, err := gzip.NewReader(&profile)
+	if err != nil {
+		t.Fatalf("expected gzip compressed profile: %s", err)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("expected gzip compressed profile: %s", err)
+	}
+
+	for _, expected := range []string{"main", "+", "*", "nanoseconds", "alloc_space"} {
+		if !bytes.Contains(data, []byte(expected)) {
+			t.Errorf("expected profile to contain string %q", expected)
+		}
+	}
+}
+
+func TestProfiler_Sources(t *testing.T) {
+	lang := NewLanguage[*context]()
+	lang.BindOperator("+", plus)
+	lang.BindLiteralEvaluator(ParseInt)
+
+	profiler := NewProfiler()
+	for _, source := range []string{"a.pala", "b.pala"} {
+		prog, err := NewParser(NewLexer(strings.NewReader("+ 1 2"), WithSource(source)), lang).Parse()
+		if err != nil {
+			t.Fatalf("expected program to be parsed:\n%s", err)
+		}
+		_ = prog.Run(&context{}, WithTracer(profiler))
+	}
+
+	lines := profiler.Lines()
+	for _, line := range []SourceLine{{Source: "a.pala"}, {Source: "b.pala"}} {
+		if lines[line].Calls != 1 {
+			t.Errorf("expected 1 call of line %s, got %+v", line, lines)
+		}
+	}
+
+	var report bytes.Buffer
+	_ = profiler.WriteReport(&report)
+	if !strings.Contains(report.String(), "a.pala:1") || !strings.Contains(report.String(), "b.pala:1") {
+		t.Errorf("expected report to contain lines of both sources, got\n%s", report.String())
+	}
+
+	var profile bytes.Buffer
+	_ = profiler.WritePprof(&profile)
+	reader, err := gzip.NewReader(&profile)
+	if err != nil {
+		t.Fatalf("expected gzip compressed profile: %s", err)
+	}
+	data, _ := io.ReadAll(reader)
+	if !bytes.Contains(data, []byte("a.pala")) || !bytes.Contains(data, []byte("b.pala")) {
+		t.Errorf("expected profile to contain the file names of both sources")
+	}
+}
+
+func TestProtoBuffer(t *testing.T) {
+	b := &protoBuffer{}
+	b.uint(1, 150)
+	b.string(2, "ab")
+	b.packedInts(3, []int64{1, 300})
+
+	expected := []byte{0x08, 0x96, 0x01, 0x12, 0x02, 'a', 'b', 0x1a, 0x03, 0x01, 0xac, 0x02}
+	if !bytes.Equal(b.bytes, expected) {
+		t.Errorf("expected %x but got %x", expected, b.bytes)
+	}
+}
@@ -1,34 +1,225 @@
 package pala
 
-import "reflect"
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+)
 
 type Program[C any] struct {
 	root      astNode[C]
 	variables map[string]interface{}
 }
 
-func (p Program[C]) Run(context C) {
-	p.root.evaluate(context)
+// RunOption configures a single run of a program.
+type RunOption func(config *runConfig)
+
+type runConfig struct {
+	tracers []Tracer
+
+	// Budgets of the run, zero means unlimited.
+	maxSteps         int
+	maxSliceElements int
+	maxDepth         int
+	timeout          time.Duration
+}
+
+// WithTracer passes the events of the run to the given tracer. It can be given multiple times to use several tracers.
+func WithTracer(tracer Tracer) RunOption {
+	return func(config *runConfig) {
+		config.tracers = append(config.tracers, tracer)
+	}
+}
+
+// runtime holds the state of a single run of a program.
+type runtime[C any] struct {
+	context   C
+	variables map[string]interface{}
+	tracer    Tracer
+	depth     int
+
+	config        runConfig
+	steps         int
+	sliceElements int
+	nesting       int // Number of lists, maps and operator calls that are being evaluated, nested in each other.
+	deadline      time.Time
+}
+
+// ErrAborted is returned when a run is aborted before the program finished, for instance by a Debugger.
+var ErrAborted = errors.New("run aborted")
+
+// RuntimeError is returned when a program fails while it runs, for instance when it reads a field of a nil pointer.
+type RuntimeError struct {
+	Pos Position
+	Msg string
+}
+
+func (e *RuntimeError) Error() string {
+	return fmt.Sprintf("%s %s", e.Pos.prefix(), e.Msg)
+}
+
+// abort is the panic value that is used to stop a run. Run recovers it and returns the contained error.
+type abort struct {
+	err error
+}
+
+// Run runs the program with the given context.
+func (p Program[C]) Run(context C, options ...RunOption) error {
+	_, _, err := p.Eval(context, options...)
+	return err
+}
+
+// Eval runs the program and returns the value of its last statement together with the type of that value.
+// Assignments and operators without a return value evaluate to nil with a nil type.
+func (p Program[C]) Eval(context C, options ...RunOption) (value interface{}, valueType reflect.Type, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			stop, isAbort := recovered.(abort)
+			if !isAbort {
+				panic(recovered)
+			}
+			value, valueType, err = nil, nil, stop.err
+		}
+	}()
+
+	return p.root.evaluate(p.newRuntime(context, options)), p.root.returnType, nil
+}
+
+func (p Program[C]) newRuntime(context C, options []RunOption) *runtime[C] {
+	var config runConfig
+	for _, option := range options {
+		option(&config)
+	}
+
+	rt := &runtime[C]{context: context, variables: p.variables, config: config}
+	if config.timeout > 0 {
+		rt.deadline = time.Now().Add(config.timeout)
+	}
+	switch len(config.tracers) {
+	case 0:
+	case 1:
+		rt.tracer = config.tracers[0]
+	default:
+		rt.tracer = multiTracer(config.tracers)
+	}
+	return rt
+}
+
+// Variable returns the current value of a variable of the program.
+func (p Program[C]) Variable(name string) (interface{}, bool) {
+	value, has := p.variables[name]
+	return value, has
 }
 
 type astNode[C any] struct {
 	returnType reflect.Type
-	evaluate   func(context C) interface{}
+	evaluate   func(rt *runtime[C]) interface{}
+
+	// The fields below describe the source of the node, they are not used during evaluation.
+	start    Position
+	end      Position
+	children []astNode[C]
+	operator *OperatorInfo
+	kind     nodeKind
+
+	rejections []LiteralRejection // Rejections of the evaluators of the expected type, for a literal evaluated by another.
+}
+
+// nodeKind distinguishes composite literals, whose elements are the children of the node, and the nil literal from
+// other nodes.
+type nodeKind int
+
+const (
+	nodeOther nodeKind = iota
+	nodeList
+	nodeMap
+	nodeTuple
+	nodeNil
+)
+
+// Expression describes an expression of a parsed program.
+type Expression struct {
+	Start    Position
+	End      Position
+	Type     reflect.Type  // nil if the expression has no value.
+	Operator *OperatorInfo // The operator if the expression is an operation, nil otherwise.
+}
+
+// ExpressionAt returns the innermost expression of the program that contains the given position.
+func (p Program[C]) ExpressionAt(pos Position) (Expression, bool) {
+	var found *astNode[C]
+	nodes := p.root.children
+	for i := 0; i < len(nodes); i++ {
+		node := nodes[i]
+		if pos.Before(node.start) || !pos.Before(node.end) {
+			continue
+		}
+		found = &nodes[i]
+		nodes = node.children
+		i = -1
+	}
+
+	if found == nil {
+		return Expression{}, false
+	}
+	return Expression{
+		Start:    found.start,
+		End:      found.end,
+		Type:     found.returnType,
+		Operator: found.operator,
+	}, true
 }
 
-// rootNode creates an astNode that evaluates all statements and returns nil.
+// rootNode creates an astNode that evaluates all statements and returns the value of the last statement.
 func rootNode[C any](statements []astNode[C]) astNode[C] {
+	var returnType reflect.Type
+	if len(statements) > 0 {
+		returnType = statements[len(statements)-1].returnType
+	}
 	return astNode[C]{
-		returnType: nil,
-		evaluate: func(context C) interface{} {
-			for _, statement := range statements {
-				statement.evaluate(context)
-			}
+		returnType: returnType,
+		children:   statements,
+		evaluate: func(rt *runtime[C]) interface{} {
+			return evaluateStatements(rt, statements)
+		},
+	}
+}
+
+// importNode creates an astNode that evaluates the statements of an imported file.
+func importNode[C any](statements []astNode[C]) astNode[C] {
+	return astNode[C]{
+		evaluate: func(rt *runtime[C]) interface{} {
+			evaluateStatements(rt, statements)
 			return nil
 		},
 	}
 }
 
+// evaluateStatements evaluates the statements in order, checking the deadline of the run and passing each statement to
+// the tracer. It returns the value of the last statement.
+func evaluateStatements[C any](rt *runtime[C], statements []astNode[C]) interface{} {
+	var result interface{}
+	for _, statement := range statements {
+		rt.checkDeadline(statement.start)
+		if rt.tracer == nil {
+			result = statement.evaluate(rt)
+			continue
+		}
+
+		event := TraceEvent{Kind: TraceStatement, Pos: statement.start, Depth: rt.depth}
+		rt.tracer.Enter(event)
+		rt.depth++
+		start := time.Now()
+		result = statement.evaluate(rt)
+		event.Duration = time.Since(start)
+		rt.depth--
+		event.Result = result
+		rt.tracer.Exit(event)
+	}
+	return result
+}
+
 // nilNode creates an astNode that evaluates to nil
 func nilNode[C any]() astNode[C] {
 	return valueNode[C](nil, nil)
@@ -38,18 +229,27 @@ func nilNode[C any]() astNode[C] {
 func valueNode[C any](returnType reflect.Type, value interface{}) astNode[C] {
 	return astNode[C]{
 		returnType: returnType,
-		evaluate:   func(context C) interface{} { return value },
+		evaluate:   func(rt *runtime[C]) interface{} { return value },
 	}
 }
 
 // sliceNode creates an astNode that evaluates to a slice of the given type.
-func sliceNode[C any](returnType reflect.Type, values []astNode[C]) astNode[C] {
+// The given position is reported when the elements exceed the budget of the run.
+func sliceNode[C any](returnType reflect.Type, pos Position, values []astNode[C]) astNode[C] {
 	return astNode[C]{
 		returnType: returnType,
-		evaluate: func(context C) interface{} {
+		evaluate: func(rt *runtime[C]) interface{} {
+			rt.enter(pos)
+			defer rt.leave()
+
 			result := reflect.MakeSlice(returnType, 0, 0)
 			for _, value := range values {
-				result = reflect.Append(result, reflect.ValueOf(value.evaluate(context)))
+				rt.allocate(pos, 1)
+				element := reflect.ValueOf(value.evaluate(rt))
+				if !element.IsValid() {
+					element = reflect.Zero(returnType.Elem())
+				}
+				result = reflect.Append(result, element)
 			}
 			return result.Interface()
 		},
@@ -58,28 +258,102 @@ func sliceNode[C any](returnType reflect.Type, values []astNode[C]) astNode[C] {
 
 // emptySliceNode creates an astNode that evaluates to an empty slice of the given type.
 func emptySliceNode[C any](returnType reflect.Type) astNode[C] {
-	return sliceNode[C](returnType, nil)
+	return sliceNode[C](returnType, Position{}, nil)
+}
+
+// mapNode creates an astNode that evaluates to a map of the given type. The entries alternate between keys and values.
+// The given position is reported when the entries exceed the budget of the run.
+func mapNode[C any](returnType reflect.Type, pos Position, entries []astNode[C]) astNode[C] {
+	return astNode[C]{
+		returnType: returnType,
+		evaluate: func(rt *runtime[C]) interface{} {
+			rt.enter(pos)
+			defer rt.leave()
+
+			result := reflect.MakeMapWithSize(returnType, len(entries)/2)
+			for i := 0; i+1 < len(entries); i += 2 {
+				rt.allocate(pos, 1)
+				key := reflect.ValueOf(entries[i].evaluate(rt))
+				value := reflect.ValueOf(entries[i+1].evaluate(rt))
+				if !value.IsValid() {
+					value = reflect.Zero(returnType.Elem())
+				}
+				result.SetMapIndex(key, value)
+			}
+			return result.Interface()
+		},
+	}
+}
+
+// structNode creates an astNode that evaluates to a struct of the given type, with the fields set to the values in
+// order.
+func structNode[C any](returnType reflect.Type, fields []astNode[C]) astNode[C] {
+	return astNode[C]{
+		returnType: returnType,
+		evaluate: func(rt *runtime[C]) interface{} {
+			result := reflect.New(returnType).Elem()
+			for i, field := range fields {
+				if value := reflect.ValueOf(field.evaluate(rt)); value.IsValid() {
+					result.Field(i).Set(value)
+				}
+			}
+			return result.Interface()
+		},
+	}
+}
+
+// emptyMapNode creates an astNode that evaluates to an empty map of the given type.
+func emptyMapNode[C any](returnType reflect.Type) astNode[C] {
+	return mapNode[C](returnType, Position{}, nil)
 }
 
 // operatorNode creates an astNode that evaluates the given operator with the given operands.
-func operatorNode[C any](returnType reflect.Type, acceptsContext bool, operator reflect.Value, operands []astNode[C]) astNode[C] {
+func operatorNode[C any](operatorToken token, returnType reflect.Type, acceptsContext bool, operator reflect.Value, operands []astNode[C]) astNode[C] {
 	return astNode[C]{
 		returnType: returnType,
-		evaluate: func(context C) interface{} {
+		evaluate: func(rt *runtime[C]) interface{} {
+			rt.enter(operatorToken.pos)
+			defer rt.leave()
+
 			var arguments []reflect.Value
 			if acceptsContext {
-				arguments = append(arguments, reflect.ValueOf(context))
+				arguments = append(arguments, reflect.ValueOf(rt.context))
 			}
 
+			var values []interface{}
 			for _, operand := range operands {
-				arguments = append(arguments, reflect.ValueOf(operand.evaluate(context)))
+				value := operand.evaluate(rt)
+				values = append(values, value)
+				argument := reflect.ValueOf(value)
+				if !argument.IsValid() {
+					// nil values are passed as the zero value of the parameter, such as a nil interface.
+					argument = reflect.Zero(operator.Type().In(len(arguments)))
+				}
+				arguments = append(arguments, argument)
 			}
 
-			result := operator.Call(arguments)
-			if returnType == nil {
-				return nil
+			rt.step(operatorToken.pos)
+			if rt.tracer == nil {
+				return callOperator(operator, arguments, returnType)
 			}
-			return result[0].Interface()
+
+			event := TraceEvent{Kind: TraceOperator, Symbol: operatorToken.value, Pos: operatorToken.pos, Depth: rt.depth, Operands: values}
+			rt.tracer.Enter(event)
+			rt.depth++
+			start := time.Now()
+			event.Result = callOperator(operator, arguments, returnType)
+			event.Duration = time.Since(start)
+			rt.depth--
+			rt.tracer.Exit(event)
+			return event.Result
 		},
 	}
 }
+
+func callOperator(operator reflect.Value, arguments []reflect.Value, returnType reflect.Type) interface{} {
+	result := operator.Call(arguments)
+	if returnType == nil {
+		return nil
+	}
+	return result[0].Interface()
+}
@@ -1,6 +1,9 @@
 package pala
 
 import (
+	"errors"
+	"fmt"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -116,3 +119,364 @@ func Test_ParseAndRun(t *testing.T) {
 		})
 	}
 }
+
+func TestProgram_Eval(t *testing.T) {
+	lang := NewLanguage[*context]()
+	lang.BindOperator("+", plus)
+	lang.BindLiteralEvaluator(ParseInt)
+
+	prog, err := NewParser(NewLexer(strings.NewReader("$a + 1 2\n+ $a 3")), lang).Parse()
+	if err != nil {
+		t.Fatalf("expected program to be parsed:\n%s", err)
+	}
+
+	value, valueType, err := prog.Eval(&context{})
+	if err != nil {
+		t.Fatalf("expected program to run: %s", err)
+	}
+	if value != 6 || valueType != reflect.TypeOf(0) {
+		t.Errorf("expected 6 of type int but got %v of type %v", value, valueType)
+	}
+}
+
+func TestProgram_ExpressionAt(t *testing.T) {
+	lang := NewLanguage[*context]()
+	lang.BindOperator("+", plus)
+	lang.BindOperator("min", smallest)
+	lang.BindLiteralEvaluator(ParseInt)
+
+	prog, err := NewParser(NewLexer(strings.NewReader("$a + 1 2\nmin (\n  [4 3]\n)\n+ $a 5")), lang).Parse()
+	if err != nil {
+		t.Fatalf("expected program to be parsed:\n%s", err)
+	}
+
+	intType := reflect.TypeOf(0)
+	tests := []struct {
+		name     string
+		pos      Position
+		found    bool
+		start    Position
+		end      Position
+		typ      reflect.Type
+		operator string
+	}{
+		{"assigned variable", Position{Line: 0, Col: 1}, true, Position{Line: 0, Col: 0}, Position{Line: 0, Col: 2}, intType, ""},
+		{"operator", Position{Line: 0, Col: 3}, true, Position{Line: 0, Col: 3}, Position{Line: 0, Col: 8}, intType, "+"},
+		{"literal", Position{Line: 0, Col: 7}, true, Position{Line: 0, Col: 7}, Position{Line: 0, Col: 8}, intType, ""},
+		{"multi-line operation", Position{Line: 1, Col: 4}, true, Position{Line: 1, Col: 0}, Position{Line: 3, Col: 1}, intType, "min"},
+		{"list", Position{Line: 2, Col: 2}, true, Position{Line: 2, Col: 2}, Position{Line: 2, Col: 7}, reflect.TypeOf([]int{}), ""},
+		{"variable", Position{Line: 4, Col: 3}, true, Position{Line: 4, Col: 2}, Position{Line: 4, Col: 4}, intType, ""},
+		{"outside of expressions", Position{Line: 0, Col: 8}, false, Position{}, Position{}, nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, found := prog.ExpressionAt(tt.pos)
+			if found != tt.found {
+				t.Fatalf("expected found to be %t but got %t", tt.found, found)
+			}
+			if expr.Start != tt.start || expr.End != tt.end || expr.Type != tt.typ {
+				t.Errorf("unexpected expression %+v", expr)
+			}
+			if (tt.operator == "" && expr.Operator != nil) || (tt.operator != "" && (expr.Operator == nil || expr.Operator.Symbol != tt.operator)) {
+				t.Errorf("expected operator '%s' but got %+v", tt.operator, expr.Operator)
+			}
+		})
+	}
+}
+
+type address struct {
+	City string
+}
+
+type user struct {
+	Name    string
+	Age     int `json:"age"`
+	Address *address
+	secret  string
+}
+
+func TestProgram_FieldAccess(t *testing.T) {
+	lang := NewLanguage[*context]()
+	lang.BindOperator("user", func(name string, age int) user { return user{Name: name, Age: age, Address: &address{City: "Utrecht"}} })
+	lang.BindOperator("nameless", func() *user { return &user{} })
+	lang.BindOperator("nobody", func() *user { return nil })
+	lang.BindOperator("id", func(s string) string { return s })
+	lang.BindOperator("+", plus)
+	lang.BindLiteralEvaluator(ParseInt)
+	lang.BindLiteralEvaluator(ParseString)
+
+	tests := []evalCase{
+		{"exact field name", "$u user ann 30\nid $u.Name", "ann", ""},
+		{"lower case field name", "$u user ann 30\n+ $u.age 1", 31, ""},
+		{"nested pointer field", "$u user ann 30\nid $u.address.city", "Utrecht", ""},
+		{"field of pointer", "$u nameless\n+ $u.age 2", 2, ""},
+		{"unknown field", "$u user ann 30\nid $u.email", nil, "[line 1] type pala.user has no field email"},
+		{"unexported field", "$u user ann 30\nid $u.secret", nil, "[line 1] field secret of type pala.user is unexported"},
+		{"field of non-struct", "$u user ann 30\nid $u.name.first", nil, "[line 1] cannot access field first of type string"},
+		{"field type mismatch", "$u user ann 30\nid $u.age", nil, "[line 1] operand 0 of operator id expects string but got int"},
+		{"missing field name", "$u user ann 30\nid $u.", nil, "[line 1] missing field name"},
+		{"assignment to field", "$u user ann 30\n$u.name id bob", nil, "[line 1] cannot assign to field $u.name"},
+		{"nil pointer", "$u nobody\nid $u.address.city", nil, "[line 1] cannot access field address of nil pointer in $u.address.city"},
+	}
+
+	runCases(t, lang, tests)
+}
+
+func TestProgram_Index(t *testing.T) {
+	lang := NewLanguage[*context]()
+	lang.BindOperator("sum", func(xs []int) int {
+		total := 0
+		for _, x := range xs {
+			total += x
+		}
+		return total
+	})
+	lang.BindOperator("users", func() []*user { return []*user{{Name: "ann"}, nil} })
+	lang.BindOperator("id", func(s string) string { return s })
+	lang.BindOperator("+", plus)
+	lang.BindLiteralEvaluator(ParseInt)
+	lang.BindLiteralEvaluator(ParseString)
+
+	tests := []evalCase{
+		{"index", "$xs [1 2 3]\n+ $xs[1] 0", 2, ""},
+		{"variable index", "$xs [1 2 3]\n$i + 1 1\n+ $xs[$i] 0", 3, ""},
+		{"slice", "$xs [1 2 3 4]\nsum $xs[1:3]", 5, ""},
+		{"open slice bounds", "$xs [1 2 3 4]\nsum $xs[:2]", 3, ""},
+		{"slice to end", "$xs [1 2 3 4]\nsum $xs[2:]", 7, ""},
+		{"nested index", "$xs [[1 2] [3 4]]\n+ $xs[1][0] 0", 3, ""},
+		{"map key", "$m {a: 1 b: 2}\n+ $m[b] 0", 2, ""},
+		{"map of lists", "$m {a: [1 2] b: [3 4]}\n+ $m[b][1] 0", 4, ""},
+		{"field of element", "$u users\nid $u[0].name", "ann", ""},
+		{"index out of range", "$xs [1 2 3]\n+ $xs[3] 0", nil, "[line 1] index 3 out of range for list of length 3"},
+		{"slice out of range", "$xs [1 2 3]\nsum $xs[2:5]", nil, "[line 1] index 2:5 out of range for list of length 3"},
+		{"missing key", "$m {a: 1}\n+ $m[b] 0", nil, "[line 1] key b not found in map"},
+		{"field of nil element", "$u users\nid $u[1].name", nil, "[line 1] cannot access field name of nil pointer in $u[1].name"},
+		{"invalid index", "$xs [1 2 3]\n+ $xs[a] 0", nil, "[line 1] invalid index a"},
+		{"index of wrong type", "$xs [1 2 3]\n$i id a\n+ $xs[$i] 0", nil, "[line 2] index $i must be of type int but got string"},
+		{"key evaluated by key type", "$m {a: 1}\n+ $m[1] 0", nil, "[line 1] key 1 not found in map"},
+		{"key of wrong type", "$m {a: 1}\n$k + 1 1\n+ $m[$k] 0", nil, "[line 2] index $k must be of type string but got int"},
+		{"index of non-list", "$i + 1 1\n+ $i[0] 0", nil, "[line 1] cannot index int"},
+		{"slice of map", "$m {a: 1}\n+ $m[a:b] 0", nil, "[line 1] cannot slice map[string]int"},
+		{"missing index", "$xs [1 2 3]\n+ $xs[] 0", nil, "[line 1] missing index"},
+		{"missing bracket", "$xs [1 2 3]\n+ $xs[1 0", nil, "[line 1] missing ] in $xs[1 0"},
+		{"type mismatch", "$xs [1 2 3]\nid $xs[0]", nil, "[line 1] operand 0 of operator id expects string but got int"},
+		{"assignment to element", "$xs [1 2 3]\n$xs[0] + 1 1", nil, "[line 1] cannot assign to element $xs[0]"},
+	}
+
+	runCases(t, lang, tests)
+}
+
+func TestProgram_IndexErrorPosition(t *testing.T) {
+	lang := NewLanguage[*context]()
+	lang.BindOperator("+", plus)
+	lang.BindLiteralEvaluator(ParseInt)
+
+	prog, err := NewParser(NewLexer(strings.NewReader("$xs [[1 2]]\n+ $xs[0][2] 0"), WithSource("main.pala")), lang).Parse()
+	if err != nil {
+		t.Fatalf("expected program to be parsed:\n%s", err)
+	}
+
+	err = prog.Run(&context{})
+	var indexErr *IndexError
+	if !errors.As(err, &indexErr) {
+		t.Fatalf("expected an index error but got %v", err)
+	}
+	expectedPos := Position{Line: 1, Col: 9, Source: "main.pala"}
+	if indexErr.Pos != expectedPos || indexErr.Index != 2 || indexErr.Length != 2 {
+		t.Errorf("unexpected index error %+v", indexErr)
+	}
+	if err.Error() != "main.pala:2:10: index 2 out of range for list of length 2" {
+		t.Errorf("unexpected message %s", err)
+	}
+}
+
+func TestProgram_Maps(t *testing.T) {
+	lang := NewLanguage[*context]()
+	lang.BindOperator("sum", func(m map[string]int) int {
+		total := 0
+		for _, v := range m {
+			total += v
+		}
+		return total
+	})
+	lang.BindOperator("size", func(m map[int][]int) int { return len(m) })
+	lang.BindOperator("get", func(m map[string]map[string]int, a, b string) int { return m[a][b] })
+	lang.BindOperator("+", plus)
+	lang.BindLiteralEvaluator(ParseInt)
+	lang.BindLiteralEvaluator(ParseString)
+
+	tests := []evalCase{
+		{"map operand", "sum {a: 1 b: 2}", 3, ""},
+		{"colon separated by whitespace", "sum {a : 1 b: 2}", 3, ""},
+		{"map variable", "$m {a: 1 b: 2 c: 3}\nsum $m", 6, ""},
+		{"variable value", "$x + 2 3\nsum {a: $x b: $x}", 10, ""},
+		{"typed keys and list values", "size {1: [1 2] 2: [3]}", 2, ""},
+		{"empty list value first", "size {1: [] 2: [3]}", 2, ""},
+		{"empty list value last", "size {1: [3] 2: []}", 2, ""},
+		{"assigned empty list value last", "$m {1: [3] 2: []}\nsize $m", 2, ""},
+		{"nested maps", "get {x: {y: 1 z: 2}} x z", 2, ""},
+		{"multi-line map", "sum {\n  a: 1\n  b: 2\n}", 3, ""},
+		{"empty map", "sum {}", 0, ""},
+		{"keys evaluated by expected type", "sum {a: 1 2: 2}", 3, ""},
+		{"mixed key types", "$m {a: 1 2: 2}", nil, "[line 0] map keys must have a single type"},
+		{"mixed value types", "sum {a: 1 b: x}", nil, "[line 0] map values must have a single type"},
+		{"duplicate key", "sum {a: 1 a: 2}", nil, "[line 0] duplicate map key a"},
+		{"missing colon", "sum {a 1}", nil, "[line 0] expected : after map key a"},
+		{"missing value", "sum {a: }", nil, "[line 0] missing value for map key a"},
+		{"unterminated map", "sum {a: 1", nil, "[line 0] unexpected end of map"},
+		{"wrong map type", "size {a: 1}", nil, "[line 0] operand 0 of operator size expects map[int][]int but got map[string]int"},
+	}
+
+	runCases(t, lang, tests)
+}
+
+type shape interface {
+	Area() int
+}
+
+type named interface {
+	shape
+	Name() string
+}
+
+type square int
+
+func (s square) Area() int      { return int(s) * int(s) }
+func (s square) Name() string   { return "square" }
+func (s square) String() string { return fmt.Sprintf("square %d", int(s)) }
+
+type rect struct{ W, H int }
+
+func (r rect) Area() int    { return r.W * r.H }
+func (r rect) Name() string { return "rect" }
+
+func parseSquare(s string) (square, error) {
+	if !strings.HasPrefix(s, "sq") {
+		return 0, fmt.Errorf("no square")
+	}
+	n, err := ParseInt(s[2:])
+	return square(n), err
+}
+
+func parseRect(s string) (rect, error) {
+	var r rect
+	if _, err := fmt.Sscanf(s, "%dx%d", &r.W, &r.H); err != nil {
+		return rect{}, err
+	}
+	return r, nil
+}
+
+func TestProgram_MixedLists(t *testing.T) {
+	lang := NewLanguage[*context]()
+	lang.BindOperator("area", func(shapes []shape) int {
+		total := 0
+		for _, s := range shapes {
+			total += s.Area()
+		}
+		return total
+	})
+	lang.BindOperator("names", func(shapes []named) string {
+		var names []string
+		for _, s := range shapes {
+			names = append(names, s.Name())
+		}
+		return strings.Join(names, ",")
+	})
+	lang.BindOperator("count", func(values []any) int { return len(values) })
+	lang.BindLiteralEvaluator(parseSquare)
+	lang.BindLiteralEvaluator(parseRect)
+	lang.BindLiteralEvaluator(ParseInt)
+
+	tests := []struct {
+		name           string
+		program        string
+		options        []ParserOption
+		expectedValue  interface{}
+		expectedType   reflect.Type
+		expectedErrMsg string
+	}{
+		{"single type list", "$l [1 2]", []ParserOption{WithMixedLists()}, nil, nil, ""},
+		{"mixed list operand", "area [sq2 2x3]", []ParserOption{WithMixedLists()}, 10, nil, ""},
+		{"mixed list takes parameter type", "names [sq2 2x3]", []ParserOption{WithMixedLists()}, "square,rect", nil, ""},
+		{"mixed list to any", "count [1 sq2 [1 2]]", []ParserOption{WithMixedLists()}, 3, nil, ""},
+		{"narrowest interface", "$l [sq2 2x3]", []ParserOption{WithMixedLists()}, nil, reflect.TypeOf([]named{}), ""},
+		{"no common interface", "$l [1 sq2]", []ParserOption{WithMixedLists()}, nil, reflect.TypeOf([]any{}), ""},
+		{"mixed list without option", "area [sq2 2x3]", nil, nil, nil, "[line 0] list must contain a single type"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prog, err := NewParser(NewLexer(strings.NewReader(tt.program)), lang, tt.options...).Parse()
+			if tt.expectedErrMsg != "" {
+				if err == nil || err.Error() != tt.expectedErrMsg {
+					t.Fatalf("expected error '%s' but got '%v'", tt.expectedErrMsg, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected program to be parsed, got %s", err)
+			}
+
+			if tt.expectedType != nil {
+				list := prog.root.children[0].children[1]
+				if list.returnType != tt.expectedType {
+					t.Errorf("expected list of type %s but got %s", tt.expectedType, list.returnType)
+				}
+				return
+			}
+
+			value, _, err := prog.Eval(&context{})
+			if err != nil {
+				t.Fatalf("expected program to run, got %s", err)
+			}
+			if !reflect.DeepEqual(value, tt.expectedValue) {
+				t.Errorf("expected %v but got %v", tt.expectedValue, value)
+			}
+		})
+	}
+}
+
+type point struct {
+	X, Y int
+}
+
+type segment struct {
+	From, To point
+	Label    string
+}
+
+func TestProgram_Tuples(t *testing.T) {
+	lang := NewLanguage[*context]()
+	lang.BindOperator("point", func(p point) point { return p })
+	lang.BindOperator("length", func(s segment) string {
+		return fmt.Sprintf("%s %d", s.Label, s.To.X-s.From.X+s.To.Y-s.From.Y)
+	})
+	lang.BindOperator("+", plus)
+	lang.BindOperator("sum", func(points []point) int {
+		total := 0
+		for _, p := range points {
+			total += p.X + p.Y
+		}
+		return total
+	})
+	lang.BindLiteralEvaluator(ParseInt)
+	lang.BindLiteralEvaluator(ParseString)
+
+	tests := []evalCase{
+		{"tuple to struct parameter", "point @(1 2)", point{1, 2}, ""},
+		{"nested tuples", "length @(@(0 0) @(3 4) a)", "a 7", ""},
+		{"tuple spread over parameters", "+ @(1 2)", 3, ""},
+		{"tuple spread with other operands", "$x + 1 1\n+ @($x)  3", 5, ""},
+		{"list of tuples", "sum [@(1 2) @(3 4)]", 10, ""},
+		{"multi-line tuple", "point @(\n  1\n  2\n)", point{1, 2}, ""},
+		{"tuple variable", "$t @(1 x)", nil, ""},
+		{"wrong arity", "point @(1 2 3)", nil, "[line 0] operand 0 of operator point expects pala.point but got struct { T0 int; T1 int; T2 int }"},
+		{"wrong element type", "point @(1 x)", nil, "[line 0] operand 0 of operator point expects pala.point but got struct { T0 int; T1 string }"},
+		{"too many spread elements", "+ @(1 2 3)", nil, "[line 0] operator + expected 2 operands but got 3"},
+		{"empty tuple", "point @()", nil, "[line 0] tuple must have at least one element"},
+		{"unterminated tuple", "point @(1 2", nil, "[line 0] unexpected end of tuple"},
+	}
+
+	runCases(t, lang, tests)
+}
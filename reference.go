@@ -0,0 +1,110 @@
+package pala
+
+import (
+	htmltemplate "html/template"
+	"io"
+	"reflect"
+	"strings"
+	"text/template"
+)
+
+// referenceData is the data that is passed to the reference templates.
+type referenceData struct {
+	Operators []OperatorInfo
+	Literals  []LiteralInfo
+}
+
+var referenceFuncs = map[string]interface{}{
+	"typeName":   typeName,
+	"typeNames":  typeNames,
+	"escapeCell": escapeMarkdownCell,
+}
+
+var markdownReference = template.Must(template.New("markdown").Funcs(referenceFuncs).Parse(
+	`# Language reference
+
+## Operators
+{{ if not .Operators }}
+This language has no operators.
+{{ else }}
+| Symbol | Operands | Returns | Context | Description |
+|--------|----------|---------|---------|-------------|
+{{- range .Operators }}
+| ` + "`{{ escapeCell .Symbol }}`" + ` | {{ escapeCell (typeNames .OperandTypes) }} | {{ escapeCell (typeName .ReturnType) }} | {{ if .AcceptsContext }}yes{{ else }}no{{ end }} | {{ escapeCell .Doc }} |
+{{- end }}
+{{ end }}
+## Literals
+{{ if not .Literals }}
+This language has no literals.
+{{ else }}
+Literals are evaluated in the following order:
+{{ range .Literals }}
+1. ` + "`{{ typeName .ReturnType }}`" + ` ({{ .Name }})
+{{- end }}
+{{ end -}}
+`))
+
+var htmlReference = htmltemplate.Must(htmltemplate.New("html").Funcs(referenceFuncs).Parse(
+	`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Language reference</title></head>
+<body>
+<h1>Language reference</h1>
+<h2>Operators</h2>
+{{ if not .Operators }}<p>This language has no operators.</p>{{ else }}<table>
+<tr><th>Symbol</th><th>Operands</th><th>Returns</th><th>Context</th><th>Description</th></tr>
+{{- range .Operators }}
+<tr><td><code>{{ .Symbol }}</code></td><td>{{ typeNames .OperandTypes }}</td><td>{{ typeName .ReturnType }}</td><td>{{ if .AcceptsContext }}yes{{ else }}no{{ end }}</td><td>{{ .Doc }}</td></tr>
+{{- end }}
+</table>{{ end }}
+<h2>Literals</h2>
+{{ if not .Literals }}<p>This language has no literals.</p>{{ else }}<p>Literals are evaluated in the following order:</p>
+<ol>
+{{- range .Literals }}
+<li><code>{{ typeName .ReturnType }}</code> ({{ .Name }})</li>
+{{- end }}
+</ol>{{ end }}
+</body>
+</html>
+`))
+
+// WriteMarkdownReference writes a Markdown reference of all operators and literals of the language to w.
+func (l *Language[C]) WriteMarkdownReference(w io.Writer) error {
+	return markdownReference.Execute(w, l.referenceData())
+}
+
+// WriteHTMLReference writes an HTML reference of all operators and literals of the language to w.
+func (l *Language[C]) WriteHTMLReference(w io.Writer) error {
+	return htmlReference.Execute(w, l.referenceData())
+}
+
+func (l *Language[C]) referenceData() referenceData {
+	return referenceData{
+		Operators: l.Operators(),
+		Literals:  l.Literals(),
+	}
+}
+
+// typeName returns the name of a type as it is shown to script authors.
+func typeName(t reflect.Type) string {
+	if t == nil {
+		return "none"
+	}
+	return t.String()
+}
+
+func typeNames(types []reflect.Type) string {
+	if len(types) == 0 {
+		return "none"
+	}
+	var names []string
+	for _, t := range types {
+		names = append(names, typeName(t))
+	}
+	return strings.Join(names, ", ")
+}
+
+func escapeMarkdownCell(s string) string {
+	s = strings.ReplaceAll(s, "|", "\\|")
+	return strings.ReplaceAll(s, "\n", " ")
+}
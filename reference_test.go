@@ -0,0 +1,71 @@
+package pala
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLanguage_Operators(t *testing.T) {
+	lang := NewLanguage[*context]()
+	lang.BindOperator("+", plus, WithDoc("Adds two integers."))
+	lang.BindOperator("echo", echo)
+
+	ops := lang.Operators()
+	if len(ops) != 2 {
+		t.Fatalf("expected 2 operators but got %d", len(ops))
+	}
+
+	if ops[0].Symbol != "+" || !ops[0].AcceptsContext || ops[0].Doc != "Adds two integers." {
+		t.Errorf("unexpected operator info %+v", ops[0])
+	}
+	if ops[0].Signature() != "+ int int -> int" {
+		t.Errorf("unexpected signature '%s'", ops[0].Signature())
+	}
+	if ops[1].Symbol != "echo" || ops[1].AcceptsContext || ops[1].ReturnType != nil {
+		t.Errorf("unexpected operator info %+v", ops[1])
+	}
+}
+
+func TestLanguage_Literals(t *testing.T) {
+	lang := NewLanguage[*context]()
+	lang.BindLiteralEvaluator(ParseInt)
+	lang.BindLiteralEvaluator(ParseString)
+
+	literals := lang.Literals()
+	if len(literals) != 2 {
+		t.Fatalf("expected 2 literals but got %d", len(literals))
+	}
+	if literals[0].ReturnType != reflect.TypeOf(0) || literals[1].ReturnType != stringType {
+		t.Errorf("unexpected literal infos %+v", literals)
+	}
+}
+
+func TestLanguage_WriteReference(t *testing.T) {
+	lang := NewLanguage[*context]()
+	lang.BindOperator("+", plus, WithDoc("Adds two integers."))
+	lang.BindOperator("echo", echo)
+	lang.BindLiteralEvaluator(ParseInt)
+
+	markdown := &strings.Builder{}
+	if err := lang.WriteMarkdownReference(markdown); err != nil {
+		t.Fatalf("expected markdown to be written: %s", err)
+	}
+	for _, expected := range []string{
+		"| `+` | int, int | int | yes | Adds two integers. |",
+		"| `echo` | interface {} | none | no |  |",
+		"1. `int`",
+	} {
+		if !strings.Contains(markdown.String(), expected) {
+			t.Errorf("expected markdown to contain '%s' but got:\n%s", expected, markdown)
+		}
+	}
+
+	html := &strings.Builder{}
+	if err := lang.WriteHTMLReference(html); err != nil {
+		t.Fatalf("expected html to be written: %s", err)
+	}
+	if !strings.Contains(html.String(), "<td>Adds two integers.</td>") {
+		t.Errorf("expected html to contain operator description but got:\n%s", html)
+	}
+}
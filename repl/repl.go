@@ -0,0 +1,225 @@
+// Package repl provides an interactive session for any pala Language.
+//
+// Each entry is parsed against the variables defined by earlier entries and run with a context that lives as long as
+// the session. The value of an entry that is not an assignment is printed using a Formatter.
+package repl
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/RoelofRuis/pala"
+)
+
+const help = `Enter statements to run them. Variables are kept between statements.
+Wrap operands in ( ) to continue a statement on the next lines.
+
+Commands:
+  :vars      list the defined variables
+  :ops       list the available operators
+  :history   list the entered statements
+  :help      show this help
+  :quit      end the session
+`
+
+// Formatter formats a value of the given type for display.
+type Formatter func(value interface{}, valueType reflect.Type) string
+
+// DefaultFormatter formats values using their default fmt representation.
+func DefaultFormatter(value interface{}, _ reflect.Type) string {
+	return fmt.Sprintf("%v", value)
+}
+
+// Option configures a REPL.
+type Option func(config *config)
+
+type config struct {
+	formatter          Formatter
+	prompt             string
+	continuationPrompt string
+}
+
+// WithFormatter sets the formatter that is used to print result values.
+func WithFormatter(formatter Formatter) Option {
+	return func(config *config) {
+		config.formatter = formatter
+	}
+}
+
+// WithPrompt sets the prompt that is shown when a new statement is expected, and the prompt that is shown when a
+// statement continues on the next line.
+func WithPrompt(prompt, continuationPrompt string) Option {
+	return func(config *config) {
+		config.prompt = prompt
+		config.continuationPrompt = continuationPrompt
+	}
+}
+
+// REPL is an interactive session for a Language.
+type REPL[C any] struct {
+	config   config
+	language *pala.Language[C]
+	context  C
+	parser   *pala.Parser[C]
+	program  pala.Program[C]
+	history  []string
+}
+
+// New constructs a REPL for the given language. The context factory is called once to create the context that is
+// shared by all statements of the session.
+func New[C any](language *pala.Language[C], newContext func() C, options ...Option) *REPL[C] {
+	cfg := config{
+		formatter:          DefaultFormatter,
+		prompt:             "> ",
+		continuationPrompt: "... ",
+	}
+	for _, option := range options {
+		option(&cfg)
+	}
+
+	return &REPL[C]{
+		config:   cfg,
+		language: language,
+		context:  newContext(),
+		parser:   pala.NewParser(pala.NewLexer(strings.NewReader("")), language),
+	}
+}
+
+// Run reads and evaluates input until the input ends or the session is quit. Results and errors are written to out.
+func (r *REPL[C]) Run(in io.Reader, out io.Writer) error {
+	scanner := bufio.NewScanner(in)
+	for {
+		input, ok := r.read(scanner, out)
+		if !ok {
+			return scanner.Err()
+		}
+
+		switch strings.TrimSpace(input) {
+		case "":
+		case ":quit":
+			return nil
+		case ":help":
+			fmt.Fprint(out, help)
+		case ":vars":
+			r.printVariables(out)
+		case ":ops":
+			r.printOperators(out)
+		case ":history":
+			for i, entry := range r.history {
+				fmt.Fprintf(out, "%d  %s\n", i+1, entry)
+			}
+		default:
+			result, err := r.Eval(input)
+			if err != nil {
+				fmt.Fprintf(out, "error: %s\n", err)
+			} else if result != "" {
+				fmt.Fprintln(out, result)
+			}
+		}
+	}
+}
+
+// read reads lines until they form complete input.
+func (r *REPL[C]) read(scanner *bufio.Scanner, out io.Writer) (string, bool) {
+	var lines []string
+	fmt.Fprint(out, r.config.prompt)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+		input := strings.Join(lines, "\n")
+		if pala.IsComplete(input) {
+			return input, true
+		}
+		fmt.Fprint(out, r.config.continuationPrompt)
+	}
+	return "", false
+}
+
+// Eval parses and runs the input against the variables of the session. It returns the formatted value of the last
+// statement, or an empty string if that statement has no value.
+func (r *REPL[C]) Eval(input string) (result string, err error) {
+	r.history = append(r.history, input)
+
+	r.parser.Reset(pala.NewLexer(strings.NewReader(input)))
+	prog, err := r.parser.Parse()
+	if err != nil {
+		return "", err
+	}
+	r.program = prog
+
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			if recoveredErr, ok := recovered.(error); ok {
+				err = recoveredErr
+			} else {
+				err = fmt.Errorf("runtime error: %v", recovered)
+			}
+		}
+	}()
+	value, valueType, err := prog.Eval(r.context)
+	if err != nil {
+		return "", err
+	}
+	if valueType == nil {
+		return "", nil
+	}
+	return r.config.formatter(value, valueType), nil
+}
+
+// History returns all input that was evaluated, oldest first.
+func (r *REPL[C]) History() []string {
+	return append([]string(nil), r.history...)
+}
+
+// Complete returns the operator symbols or variable names that complete the last word of the line, sorted
+// alphabetically. Run reads plain lines and does not complete; to offer tab completion, read the input with a line
+// editor, call Complete from its completion callback and pass each complete statement to Eval.
+func (r *REPL[C]) Complete(line string) []string {
+	word := line
+	if i := strings.LastIndexFunc(line, isWordSeparator); i >= 0 {
+		word = line[i+1:]
+	}
+
+	var candidates []string
+	if strings.HasPrefix(word, "$") {
+		for _, variable := range r.parser.Variables() {
+			candidates = append(candidates, variable.Name)
+		}
+	} else {
+		for _, op := range r.language.Operators() {
+			candidates = append(candidates, op.Symbol)
+		}
+	}
+
+	var completions []string
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, word) {
+			completions = append(completions, candidate)
+		}
+	}
+	sort.Strings(completions)
+	return completions
+}
+
+func (r *REPL[C]) printVariables(out io.Writer) {
+	for _, variable := range r.parser.Variables() {
+		value, _ := r.program.Variable(variable.Name)
+		fmt.Fprintf(out, "%s %v = %s\n", variable.Name, variable.Type, r.config.formatter(value, variable.Type))
+	}
+}
+
+func (r *REPL[C]) printOperators(out io.Writer) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for _, op := range r.language.Operators() {
+		fmt.Fprintf(w, "%s\t%s\n", op.Signature(), op.Doc)
+	}
+	_ = w.Flush()
+}
+
+func isWordSeparator(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\n' || r == '[' || r == ']' || r == '{' || r == '}' || r == '(' || r == ')'
+}
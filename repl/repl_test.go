@@ -0,0 +1,155 @@
+package repl
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/RoelofRuis/pala"
+)
+
+type log struct {
+	lines []string
+}
+
+func newTestLanguage() *pala.Language[*log] {
+	lang := pala.NewLanguage[*log]()
+	lang.BindOperator("+", func(a, b int) int { return a + b })
+	lang.BindOperator("/", func(a, b int) int { return a / b })
+	lang.BindOperator("print", func(l *log, a int) { l.lines = append(l.lines, fmt.Sprint(a)) })
+	lang.BindOperator("pick", func(a []int) int { return a[0] })
+	lang.BindOperator("lookup", func(m map[int]int, k int) int { return m[k] })
+	lang.BindLiteralEvaluator(pala.ParseInt)
+	return lang
+}
+
+func TestREPL_Run(t *testing.T) {
+	tests := []struct {
+		name           string
+		input          string
+		expectedOutput string
+	}{
+		{
+			"print result",
+			"+ 1 2\n",
+			"> 3\n> ",
+		},
+		{
+			"variables persist between lines",
+			"$a + 1 2\n+ $a $a\n",
+			"> > 6\n> ",
+		},
+		{
+			"operator without result",
+			"print 1\n",
+			"> > ",
+		},
+		{
+			"multi-line input",
+			"+ (\n  3\n  4\n)\n",
+			"> ... ... ... 7\n> ",
+		},
+		{
+			"multi-line map",
+			"lookup {\n  1: 10\n  2: 20\n} 2\n",
+			"> ... ... ... 20\n> ",
+		},
+		{
+			"list variables",
+			"$a + 1 2\n:vars\n",
+			"> > $a int = 3\n> ",
+		},
+		{
+			"parse error keeps earlier variables",
+			"$a + 1 2\n$b + $a\n$b + $a $a\n",
+			"> > error: [line 0] operator + expected 2 operands but got 1\n> > ",
+		},
+		{
+			"runtime error",
+			"/ 1 0\n",
+			"> error: runtime error: integer divide by zero\n> ",
+		},
+		{
+			"history",
+			"+ 1 2\n:history\n",
+			"> 3\n> 1  + 1 2\n> ",
+		},
+		{
+			"quit",
+			":quit\n+ 1 2\n",
+			"> ",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := New(newTestLanguage(), func() *log { return &log{} })
+
+			out := &strings.Builder{}
+			if err := r.Run(strings.NewReader(tt.input), out); err != nil {
+				t.Fatalf("expected repl to end without error: %s", err)
+			}
+
+			if out.String() != tt.expectedOutput {
+				t.Errorf("expected output '%s' but got '%s'", tt.expectedOutput, out.String())
+			}
+		})
+	}
+}
+
+func TestREPL_Context(t *testing.T) {
+	ctx := &log{}
+	r := New(newTestLanguage(), func() *log { return ctx })
+
+	for _, input := range []string{"print 1", "print 2"} {
+		if _, err := r.Eval(input); err != nil {
+			t.Fatalf("expected input to be evaluated: %s", err)
+		}
+	}
+
+	if strings.Join(ctx.lines, ",") != "1,2" {
+		t.Errorf("expected context to be shared between statements but got %v", ctx.lines)
+	}
+}
+
+func TestREPL_Formatter(t *testing.T) {
+	formatter := func(value interface{}, valueType reflect.Type) string {
+		return fmt.Sprintf("%v (%s)", value, valueType)
+	}
+	r := New(newTestLanguage(), func() *log { return &log{} }, WithFormatter(formatter))
+
+	result, err := r.Eval("+ 1 2")
+	if err != nil {
+		t.Fatalf("expected input to be evaluated: %s", err)
+	}
+	if result != "3 (int)" {
+		t.Errorf("expected formatted result '3 (int)' but got '%s'", result)
+	}
+}
+
+func TestREPL_Complete(t *testing.T) {
+	r := New(newTestLanguage(), func() *log { return &log{} })
+	if _, err := r.Eval("$value + 1 2"); err != nil {
+		t.Fatalf("expected input to be evaluated: %s", err)
+	}
+
+	tests := []struct {
+		line     string
+		expected []string
+	}{
+		{"p", []string{"pick", "print"}},
+		{"print $", []string{"$value"}},
+		{"pick [$v", []string{"$value"}},
+		{"x", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			completions := r.Complete(tt.line)
+			if !reflect.DeepEqual(completions, tt.expected) {
+				t.Errorf("expected completions %v but got %v", tt.expected, completions)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package pala
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Tracer receives an event when the evaluation of a statement or an operator call is entered and when it is exited.
+// Pass a tracer to Program.Run with WithTracer.
+type Tracer interface {
+	Enter(event TraceEvent)
+	Exit(event TraceEvent)
+}
+
+// TraceKind is the kind of node that a TraceEvent is about.
+type TraceKind int
+
+const (
+	TraceStatement TraceKind = iota
+	TraceOperator
+)
+
+// TraceEvent describes the evaluation of a statement or an operator call.
+// Operands are only set for operator calls. Result and Duration are only set when the node is exited.
+type TraceEvent struct {
+	Kind     TraceKind
+	Symbol   string
+	Pos      Position
+	Depth    int
+	Operands []interface{}
+	Result   interface{}
+	Duration time.Duration
+}
+
+// multiTracer passes all events to each of its tracers.
+type multiTracer []Tracer
+
+func (m multiTracer) Enter(event TraceEvent) {
+	for _, tracer := range m {
+		tracer.Enter(event)
+	}
+}
+
+func (m multiTracer) Exit(event TraceEvent) {
+	for _, tracer := range m {
+		tracer.Exit(event)
+	}
+}
+
+// TextTracer writes a readable trace of the run, indented by nesting depth.
+type TextTracer struct {
+	w io.Writer
+}
+
+// NewTextTracer constructs a TextTracer that writes to w.
+func NewTextTracer(w io.Writer) *TextTracer {
+	return &TextTracer{w: w}
+}
+
+func (t *TextTracer) Enter(event TraceEvent) {
+	indent := strings.Repeat("  ", event.Depth)
+	switch event.Kind {
+	case TraceStatement:
+		_, _ = fmt.Fprintf(t.w, "%s%s statement\n", indent, event.Pos.prefix())
+	case TraceOperator:
+		call := []string{event.Symbol}
+		for _, operand := range event.Operands {
+			call = append(call, fmt.Sprintf("%v", operand))
+		}
+		_, _ = fmt.Fprintf(t.w, "%s> %s\n", indent, strings.Join(call, " "))
+	}
+}
+
+func (t *TextTracer) Exit(event TraceEvent) {
+	if event.Kind != TraceOperator {
+		return
+	}
+	indent := strings.Repeat("  ", event.Depth)
+	if event.Result == nil {
+		_, _ = fmt.Fprintf(t.w, "%s< %s (%s)\n", indent, event.Symbol, event.Duration)
+		return
+	}
+	_, _ = fmt.Fprintf(t.w, "%s< %s = %v (%s)\n", indent, event.Symbol, event.Result, event.Duration)
+}
@@ -0,0 +1,117 @@
+package pala
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+type recordingTracer struct {
+	events []string
+}
+
+func (r *recordingTracer) Enter(event TraceEvent) {
+	r.events = append(r.events, fmt.Sprintf("enter %d %s %d:%d %v", event.Kind, event.Symbol, event.Pos.Line, event.Pos.Col, event.Operands))
+}
+
+func (r *recordingTracer) Exit(event TraceEvent) {
+	r.events = append(r.events, fmt.Sprintf("exit %d %s %v", event.Kind, event.Symbol, event.Result))
+}
+
+func parseTraceProgram(t *testing.T, program string) Program[*context] {
+	lang := NewLanguage[*context]()
+	lang.BindOperator("+", plus)
+	lang.BindOperator("*", mul)
+	lang.BindOperator("dbg", debug)
+	lang.BindLiteralEvaluator(ParseInt)
+
+	prog, err := NewParser(NewLexer(strings.NewReader(program)), lang).Parse()
+	if err != nil {
+		t.Fatalf("expected program to be parsed:\n%s", err)
+	}
+	return prog
+}
+
+func TestTracer(t *testing.T) {
+	prog := parseTraceProgram(t, "$a + 1 2\n* $a 3")
+
+	tracer := &recordingTracer{}
+	prog.Run(&context{}, WithTracer(tracer))
+
+	expected := []string{
+		"enter 0  0:0 []",
+		"enter 1 + 0:3 [1 2]",
+		"exit 1 + 3",
+		"exit 0  <nil>",
+		"enter 0  1:0 []",
+		"enter 1 * 1:0 [3 3]",
+		"exit 1 * 9",
+		"exit 0  9",
+	}
+	if !reflect.DeepEqual(tracer.events, expected) {
+		t.Errorf("expected events\n%s\nbut got\n%s", strings.Join(expected, "\n"), strings.Join(tracer.events, "\n"))
+	}
+}
+
+func TestTracer_Multiple(t *testing.T) {
+	prog := parseTraceProgram(t, "dbg")
+
+	first, second := &recordingTracer{}, &recordingTracer{}
+	prog.Run(&context{}, WithTracer(first), WithTracer(second))
+
+	if len(first.events) != 4 || !reflect.DeepEqual(first.events, second.events) {
+		t.Errorf("expected both tracers to receive all events but got %v and %v", first.events, second.events)
+	}
+}
+
+func TestTracer_Import(t *testing.T) {
+	lang := NewLanguage[*context]()
+	lang.BindOperator("+", plus)
+	lang.BindLiteralEvaluator(ParseInt)
+	fsys := fstest.MapFS{"lib.pala": {Data: []byte("$a + 1 2")}}
+
+	prog, err := NewParser(NewLexer(strings.NewReader("import lib.pala\n+ $a 1")), lang, WithImportFS(fsys)).Parse()
+	if err != nil {
+		t.Fatalf("expected program to be parsed:\n%s", err)
+	}
+
+	tracer := &recordingTracer{}
+	prog.Run(&context{}, WithTracer(tracer))
+
+	expected := []string{
+		"enter 0  0:0 []",
+		"enter 0  0:0 []",
+		"enter 1 + 0:3 [1 2]",
+		"exit 1 + 3",
+		"exit 0  <nil>",
+		"exit 0  <nil>",
+		"enter 0  1:0 []",
+		"enter 1 + 1:0 [3 1]",
+		"exit 1 + 4",
+		"exit 0  4",
+	}
+	if !reflect.DeepEqual(tracer.events, expected) {
+		t.Errorf("expected events\n%s\nbut got\n%s", strings.Join(expected, "\n"), strings.Join(tracer.events, "\n"))
+	}
+}
+
+func TestTextTracer(t *testing.T) {
+	prog := parseTraceProgram(t, "$a + 1 2\ndbg")
+
+	out := &strings.Builder{}
+	prog.Run(&context{}, WithTracer(NewTextTracer(out)))
+
+	trace := regexp.MustCompile(`\(.+\)`).ReplaceAllString(out.String(), "(duration)")
+	expected := "[line 0] statement\n" +
+		"  > + 1 2\n" +
+		"  < + = 3 (duration)\n" +
+		"[line 1] statement\n" +
+		"  > dbg\n" +
+		"  < dbg (duration)\n"
+	if trace != expected {
+		t.Errorf("expected trace\n%s\nbut got\n%s", expected, trace)
+	}
+}
@@ -1,12 +1,14 @@
 package pala
 
 import (
+	"encoding/hex"
 	"fmt"
 	"math/big"
 	"math/rand"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // ParseInt is a literal evaluator for integers in string representation.
@@ -57,3 +59,189 @@ func ParseRandomInt(s string) (int, error) {
 	}
 	return 0, fmt.Errorf("no random int")
 }
+
+// ParseFloat is a literal evaluator for floating point numbers such as `1.5`, `-.5` or `6.02e23`.
+func ParseFloat(s string) (float64, error) {
+	if !floatPattern.MatchString(s) {
+		return 0, fmt.Errorf("no valid float")
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+var floatPattern = regexp.MustCompile(`^[+-]?([0-9]+\.?[0-9]*|\.[0-9]+)([eE][+-]?[0-9]+)?$`)
+
+// ParseBaseInt is a literal evaluator for integers in hexadecimal, octal or binary notation, such as `0xff`, `0o17`
+// or `0b101`.
+func ParseBaseInt(s string) (int, error) {
+	digits := strings.TrimLeft(s, "+-")
+	if len(digits) < 3 || digits[0] != '0' || !strings.ContainsRune("xXoObB", rune(digits[1])) {
+		return 0, fmt.Errorf("no valid hexadecimal, octal or binary int")
+	}
+	i, err := strconv.ParseInt(s, 0, 64)
+	if err != nil {
+		return 0, err
+	}
+	return int(i), nil
+}
+
+// ParseBool is a literal evaluator for the booleans `true` and `false`.
+func ParseBool(s string) (bool, error) {
+	switch s {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+	return false, fmt.Errorf("no valid bool")
+}
+
+// ParseDuration is a literal evaluator for durations such as `1m30s`, see time.ParseDuration.
+func ParseDuration(s string) (time.Duration, error) {
+	return time.ParseDuration(s)
+}
+
+// ParseTimestamp is a literal evaluator for RFC 3339 timestamps such as `2024-05-01T12:00:00Z`.
+func ParseTimestamp(s string) (time.Time, error) {
+	return time.Parse(time.RFC3339, s)
+}
+
+// ParseDate is a literal evaluator for dates such as `2024-05-01`, which are evaluated to midnight UTC.
+func ParseDate(s string) (time.Time, error) {
+	return time.Parse(time.DateOnly, s)
+}
+
+// ByteSize is a number of bytes.
+type ByteSize int64
+
+// ParseByteSize is a literal evaluator for byte sizes such as `512B`, `1.5kB` or `10MiB`. Decimal units are powers of
+// 1000 and binary units powers of 1024.
+func ParseByteSize(s string) (ByteSize, error) {
+	match := byteSizePattern.FindStringSubmatch(s)
+	if match == nil {
+		return 0, fmt.Errorf("no valid byte size")
+	}
+	size, _ := new(big.Rat).SetString(match[1])
+	unit := byteUnits[strings.ToUpper(match[2])]
+	size.Mul(size, new(big.Rat).SetInt64(unit))
+	if !size.IsInt() {
+		return 0, fmt.Errorf("byte size %s is not a whole number of bytes", s)
+	}
+	if !size.Num().IsInt64() {
+		return 0, fmt.Errorf("byte size %s is out of range", s)
+	}
+	return ByteSize(size.Num().Int64()), nil
+}
+
+var byteSizePattern = regexp.MustCompile(`^([0-9]+(?:\.[0-9]+)?)((?:[kKMGTP]i?)?)B$`)
+
+var byteUnits = map[string]int64{
+	"": 1, "K": 1e3, "M": 1e6, "G": 1e9, "T": 1e12, "P": 1e15,
+	"KI": 1 << 10, "MI": 1 << 20, "GI": 1 << 30, "TI": 1 << 40, "PI": 1 << 50,
+}
+
+// Percentage is a fraction written as a percentage, so that `50%` is 0.5.
+type Percentage float64
+
+// ParsePercentage is a literal evaluator for percentages such as `50%` or `12.5%`.
+func ParsePercentage(s string) (Percentage, error) {
+	number, found := strings.CutSuffix(s, "%")
+	if !found || !floatPattern.MatchString(number) {
+		return 0, fmt.Errorf("no valid percentage")
+	}
+	f, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, err
+	}
+	return Percentage(f / 100), nil
+}
+
+// SemVer is a semantic version, see https://semver.org.
+type SemVer struct {
+	Major      int
+	Minor      int
+	Patch      int
+	PreRelease string // Empty if the version is not a pre-release.
+	Build      string // Empty if the version has no build metadata.
+}
+
+func (v SemVer) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.PreRelease != "" {
+		s += "-" + v.PreRelease
+	}
+	if v.Build != "" {
+		s += "+" + v.Build
+	}
+	return s
+}
+
+// ParseSemVer is a literal evaluator for semantic versions such as `1.2.3`, `v2.0.0-rc.1` or `1.0.0+build.5`.
+func ParseSemVer(s string) (SemVer, error) {
+	match := semVerPattern.FindStringSubmatch(s)
+	if match == nil {
+		return SemVer{}, fmt.Errorf("no valid semantic version")
+	}
+	var numbers [3]int
+	for i := range numbers {
+		n, err := strconv.Atoi(match[i+1])
+		if err != nil {
+			return SemVer{}, err
+		}
+		numbers[i] = n
+	}
+	return SemVer{Major: numbers[0], Minor: numbers[1], Patch: numbers[2], PreRelease: match[4], Build: match[5]}, nil
+}
+
+var semVerPattern = regexp.MustCompile(`^v?(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)` +
+	`(?:-((?:0|[1-9][0-9]*|[0-9]*[a-zA-Z-][0-9a-zA-Z-]*)(?:\.(?:0|[1-9][0-9]*|[0-9]*[a-zA-Z-][0-9a-zA-Z-]*))*))?` +
+	`(?:\+([0-9a-zA-Z-]+(?:\.[0-9a-zA-Z-]+)*))?$`)
+
+// UUID is a universally unique identifier.
+type UUID [16]byte
+
+func (u UUID) String() string {
+	h := hex.EncodeToString(u[:])
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
+
+// ParseUUID is a literal evaluator for UUIDs such as `123e4567-e89b-12d3-a456-426614174000`.
+func ParseUUID(s string) (UUID, error) {
+	var u UUID
+	if !uuidPattern.MatchString(s) {
+		return u, fmt.Errorf("no valid UUID")
+	}
+	_, err := hex.Decode(u[:], []byte(strings.ReplaceAll(s, "-", "")))
+	return u, err
+}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// ParseRegexp is a literal evaluator for regular expressions between slashes, such as `/ab+c/`. The flags `i`, `m`
+// and `s` may follow the closing slash, and `\/` matches a slash.
+func ParseRegexp(s string) (*regexp.Regexp, error) {
+	end := strings.LastIndex(s, "/")
+	if !strings.HasPrefix(s, "/") || end < 1 {
+		return nil, fmt.Errorf("no valid regular expression")
+	}
+	pattern, flags := strings.ReplaceAll(s[1:end], `\/`, "/"), s[end+1:]
+	if flags != "" {
+		if strings.Trim(flags, "ims") != "" {
+			return nil, fmt.Errorf("invalid regular expression flags %s", flags)
+		}
+		pattern = "(?" + flags + ")" + pattern
+	}
+	return regexp.Compile(pattern)
+}
+
+// ParseEscapedString is a literal evaluator for strings using double quotes, in which escape sequences such as `\n`,
+// `\"` and `\u00e9` are replaced by the characters they stand for.
+func ParseEscapedString(s string) (string, error) {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return "", fmt.Errorf("no valid quoted string")
+	}
+	unquoted, err := strconv.Unquote(s)
+	if err != nil {
+		return "", fmt.Errorf("invalid escape sequence in %s", s)
+	}
+	return unquoted, nil
+}
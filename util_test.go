@@ -3,7 +3,10 @@ package pala
 import (
 	"fmt"
 	"math/big"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestParseInt(t *testing.T) {
@@ -189,3 +192,109 @@ func TestParseRandomInt(t *testing.T) {
 		t.Errorf("did not expect error")
 	}
 }
+
+func TestLiteralEvaluators(t *testing.T) {
+	mustRegexp := func(s string) interface{} {
+		re, err := ParseRegexp(s)
+		if err != nil {
+			t.Fatalf("expected %s to be a regular expression: %s", s, err)
+		}
+		return re.String()
+	}
+
+	tests := []struct {
+		name     string
+		evaluate func(s string) (interface{}, error)
+		input    string
+		expected interface{} // nil if the input is rejected.
+	}{
+		{"float", wrap(ParseFloat), "1.5", 1.5},
+		{"float without fraction", wrap(ParseFloat), "2.", 2.0},
+		{"float with exponent", wrap(ParseFloat), "-6.02e23", -6.02e23},
+		{"float fraction only", wrap(ParseFloat), ".5", 0.5},
+		{"no float", wrap(ParseFloat), "Inf", nil},
+		{"hex int", wrap(ParseBaseInt), "0xff", 255},
+		{"octal int", wrap(ParseBaseInt), "0o17", 15},
+		{"binary int", wrap(ParseBaseInt), "-0b101", -5},
+		{"no decimal base int", wrap(ParseBaseInt), "12", nil},
+		{"invalid hex digit", wrap(ParseBaseInt), "0xfg", nil},
+		{"true", wrap(ParseBool), "true", true},
+		{"false", wrap(ParseBool), "false", false},
+		{"no bool", wrap(ParseBool), "yes", nil},
+		{"duration", wrap(ParseDuration), "1m30s", 90 * time.Second},
+		{"no duration", wrap(ParseDuration), "soon", nil},
+		{"timestamp", wrap(ParseTimestamp), "2024-05-01T12:30:00Z", time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)},
+		{"no timestamp", wrap(ParseTimestamp), "2024-05-01", nil},
+		{"date", wrap(ParseDate), "2024-05-01", time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)},
+		{"no date", wrap(ParseDate), "2024-13-01", nil},
+		{"bytes", wrap(ParseByteSize), "512B", ByteSize(512)},
+		{"decimal bytes", wrap(ParseByteSize), "1.5kB", ByteSize(1500)},
+		{"binary bytes", wrap(ParseByteSize), "10MiB", ByteSize(10 << 20)},
+		{"fractional bytes", wrap(ParseByteSize), "0.5B", nil},
+		{"bytes out of range", wrap(ParseByteSize), "10000000PB", nil},
+		{"no byte size", wrap(ParseByteSize), "10MiBs", nil},
+		{"percentage", wrap(ParsePercentage), "12.5%", Percentage(0.125)},
+		{"no percentage", wrap(ParsePercentage), "12.5", nil},
+		{"semver", wrap(ParseSemVer), "1.2.3", SemVer{Major: 1, Minor: 2, Patch: 3}},
+		{"semver with prefix and suffixes", wrap(ParseSemVer), "v2.0.0-rc.1+build.5", SemVer{Major: 2, PreRelease: "rc.1", Build: "build.5"}},
+		{"semver with leading zero", wrap(ParseSemVer), "1.02.3", nil},
+		{"uuid", wrap(ParseUUID), "123e4567-e89b-12d3-a456-426614174000", UUID{0x12, 0x3e, 0x45, 0x67, 0xe8, 0x9b, 0x12, 0xd3, 0xa4, 0x56, 0x42, 0x66, 0x14, 0x17, 0x40, 0x00}},
+		{"no uuid", wrap(ParseUUID), "123e4567e89b12d3a456426614174000", nil},
+		{"regexp", func(s string) (interface{}, error) { return mustRegexp(s), nil }, "/ab+c/", "ab+c"},
+		{"regexp with flags and slash", func(s string) (interface{}, error) { return mustRegexp(s), nil }, `/a\/b/i`, "(?i)a/b"},
+		{"regexp with invalid flag", wrap(ParseRegexp), "/abc/x", nil},
+		{"no regexp", wrap(ParseRegexp), "1/2", nil},
+		{"escaped string", wrap(ParseEscapedString), `"a\n\"b\" é"`, "a\n\"b\" é"},
+		{"invalid escape", wrap(ParseEscapedString), `"\q"`, nil},
+		{"unquoted string", wrap(ParseEscapedString), "abc", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := tt.evaluate(tt.input)
+			if tt.expected == nil {
+				if err == nil {
+					t.Fatalf("expected error but got %v", result)
+				}
+			} else if err != nil {
+				t.Fatalf("expected no error but got: %s", err)
+			} else if !reflect.DeepEqual(result, tt.expected) {
+				t.Fatalf("expected %v but got %v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestLiteralEvaluatorStrings(t *testing.T) {
+	if s := (SemVer{Major: 1, Minor: 2, Patch: 3, PreRelease: "beta", Build: "7"}).String(); s != "1.2.3-beta+7" {
+		t.Errorf("unexpected semantic version %s", s)
+	}
+	uuid := "123e4567-e89b-12d3-a456-426614174000"
+	if u, _ := ParseUUID(uuid); u.String() != uuid {
+		t.Errorf("expected %s but got %s", uuid, u)
+	}
+}
+
+// wrap turns a literal evaluator into one that returns an interface{}.
+func wrap[T any](evaluate func(s string) (T, error)) func(s string) (interface{}, error) {
+	return func(s string) (interface{}, error) {
+		return evaluate(s)
+	}
+}
+
+func TestQuotedStringTokens(t *testing.T) {
+	lang := NewLanguage[*context]()
+	lang.BindOperator("join", func(xs []string, sep string) string { return strings.Join(xs, sep) }, WithOperands("xs", "sep"))
+	lang.BindLiteralEvaluator(ParseEscapedString)
+
+	tests := map[string]string{
+		`join ["a b" "c]"] "\t"`:         "a b\tc]",
+		`join ["say \"hi\""] ""`:         `say "hi"`,
+		`join sep=", " xs=["x {y}" "é"]`: "x {y}, é",
+	}
+	for program, expected := range tests {
+		if value := evalWith(t, lang, program); value != expected {
+			t.Errorf("expected %s to evaluate to %q but got %q", program, expected, value)
+		}
+	}
+}